@@ -1,6 +1,7 @@
 // package main
 
 import (
+	"gin_learn/data_parse_binding/loginguard"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -11,12 +12,22 @@ type Login struct {
 	// binding:"required"修饰的字段，若接收为空值，则报错，是必须字段
 	User    string `form:"username" json:"user" uri:"user" xml:"user" binding:"required"`
 	Pssword string `form:"password" json:"password" uri:"password" xml:"password" binding:"required"`
+	// 连续失败多次后必填，验证码图片见 GET /captcha
+	Captcha string `form:"captcha" json:"captcha" xml:"captcha"`
 }
 
 func main() {
+	demo, err := loginguard.Setup("gin_form")
+	if err != nil {
+		panic("初始化登录示例失败: " + err.Error())
+	}
+	defer demo.Close()
+
 	// 1.创建路由
 	// 默认使用了2个中间件Logger(), Recovery()
 	r := gin.Default()
+	// form_submit.html 提交到 /loginForm，用户先通过 POST /register 注册
+	demo.Routes(r)
 	// JSON绑定
 	r.POST("/loginForm", func(c *gin.Context) {
 		// 声明接收的变量
@@ -27,8 +38,9 @@ func main() {
 			return
 		}
 		// 判断用户名密码是否正确
-		if form.User != "root" || form.Pssword != "admin" {
+		if !demo.Authenticate(c, form.User, form.Pssword, form.Captcha, func(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"status": "304"})
+		}) {
 			return
 		}
 
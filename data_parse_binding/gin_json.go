@@ -1,6 +1,7 @@
 // package main
 
 import (
+	"gin_learn/data_parse_binding/loginguard"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -11,15 +12,25 @@ type Login struct {
 	// binding:"required"修饰的字段，若接收为空值，则报错，是必须字段
 	User    string `form:"username" json:"user" uri:"user" xml:"user" binding:"required"`
 	Pssword string `form:"password" json:"password" uri:"password" xml:"password" binding:"required"`
+	// 连续失败多次后必填，验证码图片见 GET /captcha
+	Captcha string `form:"captcha" json:"captcha" xml:"captcha"`
 }
 
 func main() {
+	demo, err := loginguard.Setup("gin_json")
+	if err != nil {
+		panic("初始化登录示例失败: " + err.Error())
+	}
+	defer demo.Close()
+
 	// 1.创建路由
 	// 默认使用了2个中间件Logger(), Recovery()
 	r := gin.Default()
+	demo.Routes(r)
 
 	// JSON绑定
-	// 访问示例：curl -X POST http://127.0.0.1:8000/loginJSON -H 'content-type: applcation/json' -d "{\"user\":\"root\",\"password\":\"admin\"}"
+	// 先注册用户：curl -X POST http://127.0.0.1:8000/register -H 'content-type: application/json' -d "{\"username\":\"root\",\"password\":\"admin123\"}"
+	// 访问示例：curl -X POST http://127.0.0.1:8000/loginJSON -H 'content-type: applcation/json' -d "{\"user\":\"root\",\"password\":\"admin123\"}"
 	// 访问示例：curl -X POST http://127.0.0.1:8000/loginJSON -H 'content-type: applcation/json' -d "{\"user\":\"root\",\"password\":\"admin2\"}"
 	r.POST("loginJSON", func(c *gin.Context) {
 		// 声明接收的变量
@@ -33,8 +44,9 @@ func main() {
 		}
 
 		// 判断用户名密码是否正确
-		if json.User != "root" || json.Pssword != "admin" {
+		if !demo.Authenticate(c, json.User, json.Pssword, json.Captcha, func(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"status": "304"})
+		}) {
 			return
 		}
 
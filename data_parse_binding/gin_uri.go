@@ -1,6 +1,7 @@
 package main
 
 import (
+	"gin_learn/data_parse_binding/loginguard"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -12,22 +13,30 @@ type Login struct {
 }
 
 func main() {
+	demo, err := loginguard.Setup("gin_uri")
+	if err != nil {
+		panic("初始化登录示例失败: " + err.Error())
+	}
+	defer demo.Close()
+
 	r := gin.Default()
+	demo.Routes(r)
 
-	// Example: http://localhost:8000/login/root/admin
+	// Example: http://localhost:8000/login/root/admin123
+	// 用户 root 需要先注册：curl -X POST http://localhost:8000/register -d 'username=root&password=admin123'
 	r.GET("/login/:user/:password", func(c *gin.Context) {
 		var login Login
 		if err := c.ShouldBindUri(&login); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		if login.User == "root" && login.Password == "admin" {
-			c.JSON(http.StatusOK, gin.H{"status": "200"})
-			return
-		} else {
+		// 需要验证码时通过查询参数传入：/login/root/admin123?captcha=1234
+		if !demo.Authenticate(c, login.User, login.Password, c.Query("captcha"), func(c *gin.Context) {
 			c.JSON(203, gin.H{"status": "203"})
+		}) {
 			return
 		}
+		c.JSON(http.StatusOK, gin.H{"status": "200"})
 	})
 
 	r.Run(":8000")
@@ -0,0 +1,125 @@
+package loginguard
+
+/*
+data_parse_binding 几个登录示例共用的初始化和错误处理：用户存储（auth.Open）+ 防暴力破解（auth.Guard）+ 登录验证码。
+
+	demo, err := loginguard.Setup("gin_form")
+	defer demo.Close()
+	demo.Routes(r) // GET /captcha、POST /register
+	if !demo.Authenticate(c, user, password, captcha, invalid) { return }
+*/
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"gin_learn/other_function/verifier"
+	"gin_learn/session_control/auth"
+	"gin_learn/session_control/kv"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Demo 一个登录示例用到的依赖
+type Demo struct {
+	Service *auth.Service
+	Guard   *auth.Guard
+	Logger  *zap.Logger // 审计日志
+
+	users        *auth.KVUserStore
+	captchaStore sessions.Store
+}
+
+// Setup 打开示例 name 的用户存储（见 auth.Open，默认每个示例单独一个 bbolt 文件 users-<name>.db，
+// 先用 Routes 注册的 POST /register 创建用户再登录），创建防暴力破解器（连续失败 3 次后要求验证码，5 次后临时锁定，
+// 锁定写审计日志）和保存验证码ID的 Cookie 型 Session 存储。
+// 要让各示例和 session_control/gin_session.go 共用同一批用户，先 go run ./session_control/respd，
+// 运行时都加上环境变量 AUTH_USERS_BACKEND=redis AUTH_USERS_ADDR=127.0.0.1:6380
+func Setup(name string) (*Demo, error) {
+	users, err := auth.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	captchaStore, err := verifier.SessionConfig()
+	if err != nil {
+		_ = users.Close()
+		return nil, err
+	}
+	logger, err := zap.NewProduction()
+	if err != nil {
+		_ = users.Close()
+		return nil, err
+	}
+	guard := auth.NewGuard(kv.NewMemory(kv.DefaultJanitorInterval), auth.DefaultGuardConfig)
+	guard.Logger = logger
+	return &Demo{Service: auth.NewService(users), Guard: guard, Logger: logger, users: users, captchaStore: captchaStore}, nil
+}
+
+// Close 关闭用户存储并刷新日志
+func (d *Demo) Close() {
+	_ = d.users.Close()
+	_ = d.Logger.Sync()
+}
+
+// Routes 注册示例共用的路由：GET /captcha 登录验证码图片，POST /register 注册用户（JSON 或表单：username、password）。
+// 同时设置 r 不信任任何代理的 X-Forwarded-For，IP 失败次数按连接地址统计，客户端无法伪造 IP 绕过锁定
+func (d *Demo) Routes(r *gin.Engine) {
+	_ = r.SetTrustedProxies(nil)
+	r.Use(verifier.Session("captcha_session", d.captchaStore))
+	r.GET("/captcha", verifier.CaptchaHandler(verifier.LoginCaptcha))
+	r.POST("/register", d.register)
+}
+
+// register 注册用户，示例：curl -X POST http://127.0.0.1:8000/register -d 'username=root&password=admin123'
+func (d *Demo) register(c *gin.Context) {
+	var req struct {
+		Username string `form:"username" json:"username" binding:"required"`
+		Password string `form:"password" json:"password" binding:"required"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	user, err := d.Service.Register(c.Request.Context(), req.Username, req.Password)
+	switch {
+	case errors.Is(err, auth.ErrUserExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "用户名已被注册"})
+	case errors.Is(err, auth.ErrInvalidUsername):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "用户名只能包含字母、数字、下划线、点和横线，长度3-32"})
+	case errors.Is(err, auth.ErrWeakPassword):
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("密码至少%d位", d.Service.MinPasswordLen)})
+	case err != nil:
+		d.Logger.Error("register failed", zap.String("username", req.Username), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "注册失败"})
+	default:
+		c.JSON(http.StatusCreated, gin.H{"message": "注册成功", "user_id": user.ID})
+	}
+}
+
+// Authenticate 带防暴力破解地校验用户名密码，通过时返回 true；
+// 用户名密码错误时调用 invalid 写响应（各示例的响应格式不同），锁定、需要验证码和内部错误时写统一的响应
+func (d *Demo) Authenticate(c *gin.Context, username, password, captcha string, invalid gin.HandlerFunc) bool {
+	_, err := d.Guard.Authenticate(c.Request.Context(), d.Service, username, password, c.ClientIP(),
+		func() bool { return verifier.CaptchaVerify(c, captcha) })
+	var locked *auth.LockedError
+	switch {
+	case err == nil:
+		return true
+	case errors.As(err, &locked):
+		c.Header("Retry-After", strconv.Itoa(int(locked.RetryAfter.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "登录失败次数过多，请稍后再试"})
+	case errors.Is(err, auth.ErrCaptchaRequired):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "请输入正确的验证码", "captcha_required": true})
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		invalid(c)
+	default:
+		// 存储等内部错误只写日志，不返回给客户端
+		d.Logger.Error("login failed", zap.String("username", username), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败"})
+	}
+	return false
+}
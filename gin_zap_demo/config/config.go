@@ -1,34 +1,134 @@
 package config
 
 import (
-	"encoding/json"
-	"io/ioutil"
+	"sync/atomic"
 )
 
 // Config 整个项目的配置
 type Config struct {
-	Mode       string `json:"mode"`
-	Port       int    `json:"port"`
-	*LogConfig `json:"log"`
+	Mode       string `json:"mode" validate:"oneof=debug release test"`
+	Port       int    `json:"port" validate:"min=1,max=65535"`
+	*LogConfig `json:"log" validate:"required"`
+	Admin      *AdminConfig  `json:"admin" validate:"required"`
+	Server     *ServerConfig `json:"server" validate:"required"`
+
+	sources map[string]string // 每个配置项最终取值的来源，见 layers.go
 }
 
 // LogConfig 日志配置
+// 未配置 sinks 时，按 filename 等字段输出到单个 JSON 格式的滚动文件
 type LogConfig struct {
-	Level      string `json:"level"`
-	Filename   string `json:"filename"`
-	MaxSize    int    `json:"maxsize"`
-	MaxAge     int    `json:"max_age"`
-	MaxBackups int    `json:"max_backups"`
+	Level      string          `json:"level" validate:"loglevel"`
+	Filename   string          `json:"filename" validate:"required_without=Sinks,omitempty,writable"`
+	MaxSize    int             `json:"maxsize" validate:"gt=0"`
+	MaxAge     int             `json:"max_age" validate:"gte=0"`
+	MaxBackups int             `json:"max_backups" validate:"gte=0"`
+	Sinks      []SinkConfig    `json:"sinks" validate:"dive"`
+	Access     *AccessConfig   `json:"access" validate:"required"`
+	Sampling   *SamplingConfig `json:"sampling" validate:"required"`
+	Recovery   *RecoveryConfig `json:"recovery" validate:"required"`
+}
+
+// RecoveryConfig panic恢复配置
+type RecoveryConfig struct {
+	Stack       bool     `json:"stack"`                         // 是否记录调用栈
+	DedupWindow Duration `json:"dedup_window" validate:"gte=0"` // 同一位置的相同panic在窗口内只记录一次，0 表示不去重
+}
+
+// SamplingConfig 访问日志采样配置
+// 按路径规则跳过或 1/N 采样，慢请求和 4xx/5xx 请求不受规则影响总是记录
+type SamplingConfig struct {
+	SlowThreshold Duration     `json:"slow_threshold" validate:"gte=0"` // 耗时不低于该值的请求总是记录，0 表示不启用
+	AlwaysErrors  bool         `json:"always_errors"`                   // 4xx/5xx 请求总是记录
+	Rules         []SampleRule `json:"rules" validate:"dive"`           // 按顺序匹配，第一条匹配的规则生效
+	Burst         BurstConfig  `json:"burst"`                           // zap 的突发限流，作用于全部日志
+}
+
+// SampleRule 单条路径采样规则
+type SampleRule struct {
+	Path  string `json:"path" validate:"required"` // 精确匹配，以 * 结尾时按前缀匹配
+	Skip  bool   `json:"skip"`                     // 不记录该路径
+	Every int    `json:"every" validate:"gte=0"`   // 每 N 个请求记录 1 个，0/1 表示全部记录
+}
+
+// BurstConfig 对应 zapcore.NewSamplerWithOptions：每个 tick 内同一条消息先记录 first 条，之后每 thereafter 条记录 1 条
+type BurstConfig struct {
+	Tick       Duration `json:"tick" validate:"gte=0"` // 0 表示不启用
+	First      int      `json:"first" validate:"gte=0"`
+	Thereafter int      `json:"thereafter" validate:"gte=0"`
+}
+
+// AccessConfig 访问日志配置
+type AccessConfig struct {
+	RequestBody  int      `json:"request_body" validate:"gte=0"`  // 记录请求体的最大字节数，0 表示不记录
+	ResponseBody int      `json:"response_body" validate:"gte=0"` // 记录响应体的最大字节数，0 表示不记录
+	HeaderAllow  []string `json:"header_allow"`                   // 需要记录的请求头，"*" 表示全部
+	HeaderDeny   []string `json:"header_deny"`                    // 始终不记录的请求头
+	RedactKeys   []string `json:"redact_keys"`                    // 额外需要脱敏的字段名，追加在内置的 password/token/cookie 等之后
+}
+
+// SinkConfig 单个日志输出目标，多个 sink 通过 zapcore.NewTee 组合
+type SinkConfig struct {
+	Output     string `json:"output" validate:"required,logoutput"` // stdout / stderr / 文件路径
+	Encoder    string `json:"encoder" validate:"omitempty,oneof=json console logfmt"`
+	Color      bool   `json:"color"`                                   // console 编码器是否输出彩色级别
+	MinLevel   string `json:"min_level" validate:"omitempty,loglevel"` // 为空表示不限下界
+	MaxLevel   string `json:"max_level" validate:"omitempty,loglevel"` // 为空表示不限上界
+	MaxSize    int    `json:"maxsize" validate:"gte=0"`                // 以下为文件滚动配置，0 表示使用 lumberjack 默认值
+	MaxAge     int    `json:"max_age" validate:"gte=0"`
+	MaxBackups int    `json:"max_backups" validate:"gte=0"`
+}
+
+// IsFile sink 是否输出到文件
+func (s SinkConfig) IsFile() bool {
+	return s.Output != "stdout" && s.Output != "stderr"
+}
+
+// AdminConfig 管理接口配置
+type AdminConfig struct {
+	// Token 访问 /admin 路由组所需的 Bearer token，为空表示关闭管理接口
+	Token string `json:"token" validate:"omitempty,min=16" secret:"true"`
 }
 
-// Conf 全局配置变量
+// ServerConfig http服务生命周期配置
+type ServerConfig struct {
+	DrainTimeout Duration `json:"drain_timeout" validate:"gt=0"` // 退出时等待在途请求完成的最长时间
+	ReadyDelay   Duration `json:"ready_delay" validate:"gte=0"`  // 置为未就绪后、开始关闭前的等待时间，留给负载均衡摘除流量
+}
+
+// Conf 全局配置变量，保存启动时加载的配置；热加载后的最新配置请使用 Current()
 var Conf = new(Config)
 
-// Init 初始化配置；从指定文件加载配置文件
+// current 当前生效的配置快照，热加载时整体替换
+var current atomic.Pointer[Config]
+
+// Init 初始化配置；在默认值之上叠加配置文件和 GIN_DEMO_* 环境变量，filePath 为空时跳过配置文件
 func Init(filePath string) error {
-	b, err := ioutil.ReadFile(filePath)
+	c, err := load(filePath)
 	if err != nil {
 		return err
 	}
-	return json.Unmarshal(b, Conf)
+	Conf = c
+	current.Store(c)
+	return nil
+}
+
+// Current 返回当前生效的配置快照，调用方不应修改返回值
+func Current() *Config {
+	if c := current.Load(); c != nil {
+		return c
+	}
+	return Conf
+}
+
+// load 分层加载并校验配置，返回一份新的配置
+func load(filePath string) (*Config, error) {
+	c, err := loadLayers(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if err = Validate(c); err != nil {
+		return nil, err
+	}
+	return c, nil
 }
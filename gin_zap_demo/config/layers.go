@@ -0,0 +1,376 @@
+package config
+
+import (
+	"encoding"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/goccy/go-yaml"
+)
+
+/*
+配置按以下顺序分层加载，后面的层覆盖前面的层：
+  1. 内置默认值 Defaults()
+  2. 配置文件，按扩展名识别 .json / .yaml / .yml / .toml
+  3. GIN_DEMO_* 环境变量，例如 log.max_age 对应 GIN_DEMO_LOG_MAX_AGE
+  4. 命令行参数，例如 --log.level=info
+
+每一层都以 json tag 拼出的点分路径（mode、log.maxsize ...）作为统一的 key，
+因此 Config/LogConfig 的每个字段在每一层都可以被设置，并记录其最终取值的来源。
+*/
+
+// EnvPrefix 环境变量前缀
+const EnvPrefix = "GIN_DEMO_"
+
+// 配置来源
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Defaults 内置默认配置
+func Defaults() *Config {
+	return &Config{
+		Mode: "debug",
+		Port: 8080,
+		LogConfig: &LogConfig{
+			Level:      "info",
+			Filename:   "./app.log",
+			MaxSize:    100,
+			MaxAge:     7,
+			MaxBackups: 10,
+			Access:     &AccessConfig{},
+			Sampling:   &SamplingConfig{AlwaysErrors: true},
+			Recovery:   &RecoveryConfig{Stack: true, DedupWindow: Duration(time.Minute)},
+		},
+		Admin:  &AdminConfig{},
+		Server: &ServerConfig{DrainTimeout: Duration(10 * time.Second)},
+	}
+}
+
+// CommandCheckConfig 子命令：只加载并校验配置，供 CI 使用
+const CommandCheckConfig = "check-config"
+
+func isCommand(s string) bool {
+	return s == CommandCheckConfig
+}
+
+// Flags 命令行参数的解析结果
+type Flags struct {
+	Command     string   // 子命令，如 check-config；为空表示启动服务
+	File        string   // 配置文件路径，--config 或第一个位置参数
+	PrintConfig bool     // --print-config，打印最终配置及每个值的来源
+	Args        []string // 其余位置参数
+
+	overrides map[string]string
+}
+
+// cliOverrides 启动时解析得到的命令行覆盖项，热加载时同样生效
+var cliOverrides map[string]string
+
+// ParseFlags 解析命令行参数；每个配置项都对应一个同名参数，例如 --port、--log.filename
+func ParseFlags(name string, args []string) (*Flags, error) {
+	f := &Flags{overrides: make(map[string]string)}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&f.File, "config", "", "config file (.json/.yaml/.toml)")
+	fs.BoolVar(&f.PrintConfig, "print-config", false, "print the effective config with the source of each value and exit")
+
+	def := Defaults()
+	values := make(map[string]*string)
+	for _, fd := range fields() {
+		values[fd.key] = fs.String(fd.key, "", fmt.Sprintf("override %s (default %s)", fd.key, fd.format(def)))
+	}
+	// 位置参数依次识别为子命令和配置文件路径，之后仍可继续跟参数
+	// 兼容旧的启动方式：go run ./gin_zap_demo/main.go ./gin_zap_demo/config.json
+	rest := args
+	for {
+		if err := fs.Parse(rest); err != nil {
+			return nil, err
+		}
+		rest = fs.Args()
+		if len(rest) == 0 {
+			break
+		}
+		if f.Command == "" && f.File == "" && isCommand(rest[0]) {
+			f.Command = rest[0]
+		} else if f.File == "" && filepath.Ext(rest[0]) != "" {
+			f.File = rest[0]
+		} else {
+			f.Args = rest
+			break
+		}
+		rest = rest[1:]
+	}
+	fs.Visit(func(fl *flag.Flag) {
+		if v, ok := values[fl.Name]; ok {
+			f.overrides[fl.Name] = *v
+		}
+	})
+	return f, nil
+}
+
+// InitWithFlags 按 默认值 < 配置文件 < 环境变量 < 命令行参数 的顺序初始化配置
+func InitWithFlags(f *Flags) error {
+	cliOverrides = f.overrides
+	return Init(f.File)
+}
+
+// Print 打印配置的最终取值及其来源
+func (c *Config) Print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, fd := range fields() {
+		fmt.Fprintf(tw, "%s\t= %s\t(%s)\n", fd.key, fd.format(c), c.sources[fd.key])
+	}
+	return tw.Flush()
+}
+
+// Source 返回某个配置项（如 "log.level"）最终取值的来源
+func (c *Config) Source(key string) string {
+	return c.sources[key]
+}
+
+// loadLayers 依次叠加各层配置
+func loadLayers(filePath string) (*Config, error) {
+	c := Defaults()
+	c.sources = make(map[string]string)
+	for _, fd := range fields() {
+		c.sources[fd.key] = SourceDefault
+	}
+
+	if filePath != "" {
+		values, err := readFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		if err = c.apply(values, SourceFile+": "+filePath); err != nil {
+			return nil, err
+		}
+	}
+
+	env := make(map[string]any)
+	for _, fd := range fields() {
+		if v, ok := os.LookupEnv(fd.envName()); ok {
+			env[fd.key] = v
+		}
+	}
+	if err := c.apply(env, SourceEnv); err != nil {
+		return nil, err
+	}
+
+	flags := make(map[string]any, len(cliOverrides))
+	for k, v := range cliOverrides {
+		flags[k] = v
+	}
+	if err := c.apply(flags, SourceFlag); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// apply 把一层的 key->value 写入配置并记录来源
+func (c *Config) apply(values map[string]any, source string) error {
+	for _, fd := range fields() {
+		raw, ok := values[fd.key]
+		if !ok {
+			continue
+		}
+		if err := fd.set(c, raw); err != nil {
+			return fmt.Errorf("config: %s (%s): %w", fd.key, source, err)
+		}
+		switch source {
+		case SourceEnv:
+			c.sources[fd.key] = SourceEnv + ": " + fd.envName()
+		case SourceFlag:
+			c.sources[fd.key] = SourceFlag + ": --" + fd.key
+		default:
+			c.sources[fd.key] = source
+		}
+	}
+	return nil
+}
+
+// readFile 按扩展名解析配置文件，并展开成点分路径的 key
+func readFile(filePath string) (map[string]any, error) {
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	switch ext := strings.ToLower(filepath.Ext(filePath)); ext {
+	case ".json":
+		err = json.Unmarshal(b, &m)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &m)
+	case ".toml":
+		err = toml.Unmarshal(b, &m)
+	default:
+		return nil, fmt.Errorf("config: unsupported file format %q", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config: parse %s: %w", filePath, err)
+	}
+	out := make(map[string]any)
+	if err = flatten("", m, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// flatten 把嵌套的 map 展开为 log.level 形式的 key，遇到已知配置项即停止展开
+func flatten(prefix string, m map[string]any, out map[string]any) error {
+	for k, v := range m {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if _, ok := lookupField(key); ok {
+			out[key] = v
+			continue
+		}
+		if v == nil {
+			continue // 空的配置段，例如 "log": null
+		}
+		sub, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("config: unknown key %q", key)
+		}
+		if err := flatten(key, sub, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// field 一个可配置项
+type field struct {
+	key    string // 点分路径，如 log.max_age
+	index  [][]int
+	secret bool // 打印配置时隐藏取值，对应 tag secret:"true"
+}
+
+// envName 配置项对应的环境变量名
+func (f field) envName() string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(f.key, ".", "_"))
+}
+
+// value 定位到配置项对应的字段，必要时为嵌入的指针结构体分配内存
+func (f field) value(c *Config) reflect.Value {
+	v := reflect.ValueOf(c).Elem()
+	for _, idx := range f.index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.FieldByIndex(idx)
+	}
+	return v
+}
+
+func (f field) get(c *Config) any {
+	return f.value(c).Interface()
+}
+
+// format 标量直接输出，列表/结构体等输出为 JSON
+func (f field) format(c *Config) string {
+	v := f.value(c)
+	if f.secret && !v.IsZero() {
+		return "******"
+	}
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Struct:
+		b, _ := json.Marshal(v.Interface())
+		return string(b)
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// set 把某一层的原始值写入字段；字符串按字段类型解析，其余类型经 JSON 转换
+func (f field) set(c *Config, raw any) error {
+	fv := f.value(c)
+	if s, ok := raw.(string); ok {
+		if tu, ok := fv.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return tu.UnmarshalText([]byte(s))
+		}
+		switch fv.Kind() {
+		case reflect.String:
+			fv.SetString(s)
+			return nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+			if err != nil {
+				return err
+			}
+			fv.SetInt(n)
+			return nil
+		case reflect.Bool:
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return err
+			}
+			fv.SetBool(b)
+			return nil
+		default:
+			return json.Unmarshal([]byte(s), fv.Addr().Interface())
+		}
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, fv.Addr().Interface())
+}
+
+// fields 遍历 Config 的 json tag，生成全部配置项
+func fields() []field {
+	var out []field
+	var walk func(t reflect.Type, prefix string, path [][]int)
+	walk = func(t reflect.Type, prefix string, path [][]int) {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			name := strings.Split(sf.Tag.Get("json"), ",")[0]
+			if !sf.IsExported() || name == "" || name == "-" {
+				continue
+			}
+			key := name
+			if prefix != "" {
+				key = prefix + "." + name
+			}
+			p := append(append([][]int{}, path...), sf.Index)
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr && ft.Elem().Kind() == reflect.Struct {
+				walk(ft.Elem(), key, p)
+				continue
+			}
+			out = append(out, field{key: key, index: p, secret: sf.Tag.Get("secret") == "true"})
+		}
+	}
+	walk(reflect.TypeOf(Config{}), "", nil)
+	return out
+}
+
+func lookupField(key string) (field, bool) {
+	for _, fd := range fields() {
+		if fd.key == key {
+			return fd, true
+		}
+	}
+	return field{}, false
+}
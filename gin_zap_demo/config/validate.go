@@ -0,0 +1,158 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap/zapcore"
+)
+
+// validate 配置校验引擎，字段名使用 json tag，错误路径形如 log.maxsize
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(func(sf reflect.StructField) string {
+		name := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	_ = v.RegisterValidation("loglevel", validLogLevel)
+	_ = v.RegisterValidation("writable", writablePath)
+	_ = v.RegisterValidation("logoutput", func(fl validator.FieldLevel) bool {
+		if s := fl.Field().String(); s == "stdout" || s == "stderr" {
+			return true
+		}
+		return writablePath(fl)
+	})
+	return v
+}
+
+// FieldError 单个配置项的校验错误
+type FieldError struct {
+	Field   string // json 路径，如 log.maxsize
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// ValidationErrors 一次校验发现的全部问题
+type ValidationErrors []FieldError
+
+func (es ValidationErrors) Error() string {
+	lines := make([]string, 0, len(es)+1)
+	lines = append(lines, fmt.Sprintf("config: %d invalid field(s):", len(es)))
+	for _, e := range es {
+		lines = append(lines, "  "+e.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Validate 校验整个配置，一次性返回所有问题（ValidationErrors）
+func Validate(c *Config) error {
+	err := validate.Struct(c)
+	if err == nil {
+		return nil
+	}
+	var ves validator.ValidationErrors
+	if !errors.As(err, &ves) {
+		return err
+	}
+	out := make(ValidationErrors, 0, len(ves))
+	for _, fe := range ves {
+		out = append(out, FieldError{Field: fieldPath(fe), Message: message(fe)})
+	}
+	return out
+}
+
+// fieldPath 去掉命名空间开头的结构体名 Config.
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.IndexByte(ns, '.'); i >= 0 {
+		return ns[i+1:]
+	}
+	return ns
+}
+
+// message 把校验 tag 翻译成可读的错误描述
+func message(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "required_without":
+		return "is required when " + strings.ToLower(fe.Param()) + " is empty"
+	case "gt":
+		return "must be > " + fe.Param()
+	case "gte", "min":
+		if fe.Kind() == reflect.String {
+			return "must be at least " + fe.Param() + " characters"
+		}
+		return "must be >= " + fe.Param()
+	case "lte", "max":
+		return "must be <= " + fe.Param()
+	case "oneof":
+		return fmt.Sprintf("must be one of [%s], got %q", fe.Param(), fmt.Sprint(fe.Value()))
+	case "loglevel":
+		return fmt.Sprintf("must be one of [debug info warn error dpanic panic fatal], got %q", fmt.Sprint(fe.Value()))
+	case "writable", "logoutput":
+		return fmt.Sprintf("is not writable: %q", fmt.Sprint(fe.Value()))
+	default:
+		return fmt.Sprintf("failed on %q", fe.Tag())
+	}
+}
+
+// validLogLevel 校验zap日志级别
+func validLogLevel(fl validator.FieldLevel) bool {
+	var l zapcore.Level
+	return l.UnmarshalText([]byte(fl.Field().String())) == nil
+}
+
+// writablePath 校验日志文件可写：文件已存在则尝试追加打开，
+// 否则在最近一个已存在的上级目录中创建临时文件（lumberjack 会自动创建缺失的目录）
+func writablePath(fl validator.FieldLevel) bool {
+	path := fl.Field().String()
+	if path == "" {
+		return false
+	}
+	if fi, err := os.Stat(path); err == nil {
+		if fi.IsDir() {
+			return false
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			return false
+		}
+		_ = f.Close()
+		return true
+	}
+	dir := filepath.Dir(path)
+	for {
+		if fi, err := os.Stat(dir); err == nil {
+			if !fi.IsDir() {
+				return false
+			}
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+	f, err := os.CreateTemp(dir, ".writable-*")
+	if err != nil {
+		return false
+	}
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+	return true
+}
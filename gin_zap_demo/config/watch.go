@@ -0,0 +1,118 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// Event 配置变更事件，Old/New 分别是变更前后的配置快照
+type Event struct {
+	Old *Config
+	New *Config
+}
+
+// LevelChanged 日志级别是否发生变化
+func (e Event) LevelChanged() bool {
+	return e.Old.Level != e.New.Level
+}
+
+// ModeChanged gin运行模式是否发生变化
+func (e Event) ModeChanged() bool {
+	return e.Old.Mode != e.New.Mode
+}
+
+// Subscriber 配置变更订阅者；返回错误时本次变更整体回滚
+type Subscriber func(Event) error
+
+var (
+	reloadMu    sync.Mutex
+	subscribers []Subscriber
+)
+
+// Subscribe 注册配置变更订阅者，按注册顺序依次通知
+func Subscribe(s Subscriber) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	subscribers = append(subscribers, s)
+}
+
+// Reload 重新加载配置文件并通知订阅者
+// 文件格式错误、校验失败或任一订阅者应用失败时，已应用的订阅者会收到反向事件，当前配置保持不变
+func Reload(filePath string) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	newConf, err := load(filePath)
+	if err != nil {
+		return err
+	}
+	e := Event{Old: Current(), New: newConf}
+	for i, s := range subscribers {
+		if err = s(e); err != nil {
+			rollback := Event{Old: e.New, New: e.Old}
+			for j := i - 1; j >= 0; j-- {
+				_ = subscribers[j](rollback)
+			}
+			return fmt.Errorf("config: apply change: %w", err)
+		}
+	}
+	current.Store(newConf)
+	return nil
+}
+
+// reloadDelay 合并编辑器保存文件时连续触发的多个事件
+const reloadDelay = 100 * time.Millisecond
+
+// Watch 监听配置文件的变化并自动 Reload，返回的 stop 函数用于停止监听
+// 监听的是文件所在目录，这样编辑器"写临时文件再重命名"的保存方式也能被感知
+func Watch(filePath string) (stop func() error, err error) {
+	abs, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, err
+	}
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err = w.Add(filepath.Dir(abs)); err != nil {
+		_ = w.Close()
+		return nil, err
+	}
+
+	go func() {
+		var timer *time.Timer
+		for {
+			select {
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(ev.Name) != abs || ev.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(reloadDelay, func() {
+					if err := Reload(abs); err != nil {
+						zap.L().Error("reload config failed, keep previous config",
+							zap.String("file", abs), zap.Error(err))
+						return
+					}
+					zap.L().Info("config reloaded", zap.String("file", abs))
+				})
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				zap.L().Error("watch config failed", zap.String("file", abs), zap.Error(err))
+			}
+		}
+	}()
+	return w.Close, nil
+}
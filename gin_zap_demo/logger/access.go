@@ -0,0 +1,132 @@
+package logger
+
+import (
+	"bytes"
+	"io"
+	"time"
+
+	"gin_learn/gin_zap_demo/config"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// LoggerConfig 访问日志配置
+type LoggerConfig struct {
+	RequestBodyLimit  int            // 记录请求体的最大字节数，0 表示不记录
+	ResponseBodyLimit int            // 记录响应体的最大字节数，0 表示不记录
+	HeaderAllow       []string       // 需要记录的请求头，"*" 表示全部；为空表示不记录请求头
+	HeaderDeny        []string       // 始终不记录的请求头，优先于 HeaderAllow
+	Redactor          Redactor       // 脱敏器，为空时使用 DefaultRedactor
+	Sampler           *AccessSampler // 采样器，为空时记录全部请求
+}
+
+// NewLoggerConfig 由 log.access 和 log.sampling 配置生成访问日志配置，redact_keys 会追加到默认敏感字段之后
+func NewLoggerConfig(cfg *config.LogConfig) LoggerConfig {
+	return LoggerConfig{
+		RequestBodyLimit:  cfg.Access.RequestBody,
+		ResponseBodyLimit: cfg.Access.ResponseBody,
+		HeaderAllow:       cfg.Access.HeaderAllow,
+		HeaderDeny:        cfg.Access.HeaderDeny,
+		Redactor:          newRedactor(cfg.Access),
+		Sampler:           NewAccessSampler(cfg.Sampling),
+	}
+}
+
+// newRedactor 默认敏感字段加上 log.access.redact_keys
+func newRedactor(cfg *config.AccessConfig) Redactor {
+	return NewKeyRedactor(append(append([]string{}, DefaultSensitiveKeys...), cfg.RedactKeys...)...)
+}
+
+// GinLoggerWithConfig 按配置记录访问日志，可选记录请求头、请求体和响应体，敏感字段经 Redactor 脱敏
+func GinLoggerWithConfig(conf LoggerConfig) gin.HandlerFunc {
+	if conf.Redactor == nil {
+		conf.Redactor = DefaultRedactor
+	}
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		query := c.Request.URL.RawQuery
+
+		var reqBody []byte
+		var reqTruncated bool
+		if conf.RequestBodyLimit > 0 && c.Request.Body != nil {
+			reqBody, reqTruncated = captureRequestBody(c, conf.RequestBodyLimit)
+		}
+		var bw *bodyWriter
+		if conf.ResponseBodyLimit > 0 {
+			bw = &bodyWriter{ResponseWriter: c.Writer, limit: conf.ResponseBodyLimit}
+			c.Writer = bw
+		}
+
+		c.Next()
+
+		cost := time.Since(start)
+		if !conf.Sampler.Keep(path, c.Writer.Status(), cost) {
+			return
+		}
+		fields := []zap.Field{
+			zap.Int("status", c.Writer.Status()),
+			zap.String("method", c.Request.Method),
+			zap.String("path", path),
+			zap.String("query", redactQuery(query, conf.Redactor)),
+			zap.String("ip", c.ClientIP()),
+			zap.String("user-agent", c.Request.UserAgent()),
+			zap.String("errors", c.Errors.ByType(gin.ErrorTypePrivate).String()),
+			zap.Duration("cost", cost),
+		}
+		if len(conf.HeaderAllow) > 0 {
+			fields = append(fields, zap.Any("headers", redactHeaders(c.Request.Header, conf.HeaderAllow, conf.HeaderDeny, conf.Redactor)))
+		}
+		if reqBody != nil {
+			fields = append(fields, zap.String("request_body", redactBody(c.ContentType(), reqBody, reqTruncated, conf.Redactor)))
+		}
+		if bw != nil {
+			fields = append(fields, zap.String("response_body", redactBody(c.Writer.Header().Get("Content-Type"), bw.body.Bytes(), bw.truncated, conf.Redactor)))
+		}
+		FromContext(c).Info(path, fields...)
+	}
+}
+
+// captureRequestBody 读取请求体的前 limit 个字节用于记录，并把已读部分拼回 Body，不影响后续处理函数读取
+func captureRequestBody(c *gin.Context, limit int) ([]byte, bool) {
+	body := c.Request.Body
+	buf, err := io.ReadAll(io.LimitReader(body, int64(limit)+1))
+	if err != nil {
+		return nil, false
+	}
+	c.Request.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf), body), body}
+	if len(buf) > limit {
+		return buf[:limit], true
+	}
+	return buf, false
+}
+
+// bodyWriter 在写响应的同时保留前 limit 个字节
+type bodyWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (w *bodyWriter) Write(b []byte) (int, error) {
+	w.capture(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *bodyWriter) capture(b []byte) {
+	if room := w.limit - w.body.Len(); room < len(b) {
+		w.truncated = true
+		b = b[:max(room, 0)]
+	}
+	w.body.Write(b)
+}
@@ -0,0 +1,157 @@
+package logger
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gin_learn/gin_zap_demo/config"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// maxOverrideTTL 临时调整日志级别的最长有效期
+const maxOverrideTTL = 24 * time.Hour
+
+// levelState 记录日志级别的来源：base 来自配置文件或永久修改，override 为带 TTL 的临时修改
+type levelState struct {
+	mu        sync.Mutex
+	base      zapcore.Level
+	timer     *time.Timer
+	expiresAt time.Time
+}
+
+var levels levelState
+
+// setBaseLevel 修改基础级别；存在临时修改时只更新 base，到期后恢复为新的 base
+func setBaseLevel(l zapcore.Level, by string) {
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+	levels.base = l
+	if levels.timer != nil {
+		lg.Info("log base level changed, temporary override still active",
+			zap.String("by", by), zap.Stringer("base", l),
+			zap.Stringer("level", atomicLevel.Level()), zap.Time("expires_at", levels.expiresAt))
+		return
+	}
+	changeLevel(l, by)
+}
+
+// SetLevel 运行时修改日志级别；ttl>0 时为临时修改，到期后自动恢复为 base 级别
+func SetLevel(l zapcore.Level, ttl time.Duration, by string) {
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+	if levels.timer != nil {
+		levels.timer.Stop()
+		levels.timer, levels.expiresAt = nil, time.Time{}
+	}
+	if ttl <= 0 {
+		levels.base = l
+		changeLevel(l, by)
+		return
+	}
+	levels.expiresAt = time.Now().Add(ttl)
+	levels.timer = time.AfterFunc(ttl, revertLevel)
+	changeLevel(l, by, zap.Duration("ttl", ttl))
+}
+
+// revertLevel 临时修改到期，恢复为 base 级别
+func revertLevel() {
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+	levels.timer, levels.expiresAt = nil, time.Time{}
+	changeLevel(levels.base, "ttl-expired")
+}
+
+// changeLevel 修改 atomicLevel 并记录日志；调用方需持有 levels.mu
+func changeLevel(l zapcore.Level, by string, fields ...zap.Field) {
+	from := atomicLevel.Level()
+	atomicLevel.SetLevel(l)
+	// 通过 auditLg 记录，不受 atomicLevel 限制，调到 error/fatal 等级别时这条日志也能输出
+	auditLg.Warn("log level changed", append([]zap.Field{
+		zap.Stringer("from", from), zap.Stringer("to", l), zap.String("by", by),
+	}, fields...)...)
+}
+
+// levelView 查询日志级别的响应
+type levelView struct {
+	Level     string     `json:"level"`
+	Base      string     `json:"base"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+func currentLevel() levelView {
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+	v := levelView{Level: atomicLevel.Level().String(), Base: levels.base.String()}
+	if levels.timer != nil {
+		t := levels.expiresAt
+		v.ExpiresAt = &t
+	}
+	return v
+}
+
+// AdminAuth 校验管理接口的 Bearer token，token 取自当前生效的配置，支持热加载
+func AdminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := config.Current().Admin.Token
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin api disabled"})
+			return
+		}
+		got, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			lg.Warn("admin auth failed", zap.String("path", c.Request.URL.Path), zap.String("ip", c.ClientIP()))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RegisterAdmin 注册管理接口路由组：
+//
+//	GET /admin/loglevel                                   查看当前级别
+//	PUT /admin/loglevel  {"level":"debug","ttl":"10m"}    修改级别，ttl 为空表示永久修改
+//	GET /admin/logstats                                   访问日志采样和突发限流的丢弃统计
+func RegisterAdmin(r gin.IRouter) {
+	g := r.Group("/admin", AdminAuth())
+	{
+		g.GET("/loglevel", func(c *gin.Context) {
+			c.JSON(http.StatusOK, currentLevel())
+		})
+		g.PUT("/loglevel", putLogLevel)
+		g.GET("/logstats", getLogStats)
+	}
+}
+
+func putLogLevel(c *gin.Context) {
+	var req struct {
+		Level string `json:"level" binding:"required"`
+		TTL   string `json:"ttl"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var l zapcore.Level
+	if err := l.UnmarshalText([]byte(req.Level)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid level: " + req.Level})
+		return
+	}
+	var ttl time.Duration
+	if req.TTL != "" {
+		d, err := time.ParseDuration(req.TTL)
+		if err != nil || d <= 0 || d > maxOverrideTTL {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ttl must be a duration in (0, 24h]"})
+			return
+		}
+		ttl = d
+	}
+	SetLevel(l, ttl, "admin:"+c.ClientIP())
+	c.JSON(http.StatusOK, currentLevel())
+}
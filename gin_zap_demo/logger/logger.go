@@ -1,14 +1,10 @@
 package logger
 
 import (
+	"errors"
 	"gin_learn/gin_zap_demo/config"
-	"net"
-	"net/http"
-	"net/http/httputil"
-	"os"
-	"runtime/debug"
-	"strings"
-	"time"
+	"io"
+	"syscall"
 
 	"github.com/gin-gonic/gin"
 	"github.com/natefinch/lumberjack"
@@ -18,30 +14,96 @@ import (
 
 var lg *zap.Logger
 
+// auditLg 写到与 lg 相同的 sink，但不受 atomicLevel 限制，日志级别调到 error 以上时审计日志仍然输出
+var auditLg *zap.Logger
+
+// files 所有 sink 打开的日志文件，退出时由 Sync 关闭
+var files []io.Closer
+
+// atomicLevel 可在运行时调整的日志级别，配置热加载和管理接口直接修改它而无需重建logger
+var atomicLevel = zap.NewAtomicLevel()
+
 // InitLogger 初始化Logger
+// 每个 sink 对应一个 core，通过 zapcore.NewTee 组合，一条日志会分发到所有级别范围匹配的 sink
 func InitLogger(cfg *config.LogConfig) (err error) {
-	writeSyncer := getLogWriter(cfg.Filename, cfg.MaxSize, cfg.MaxBackups, cfg.MaxAge)
-	encoder := getEncoder()
-	var l = new(zapcore.Level)
-	err = l.UnmarshalText([]byte(cfg.Level))
+	err = atomicLevel.UnmarshalText([]byte(cfg.Level))
 	if err != nil {
 		return
 	}
-	core := zapcore.NewCore(encoder, writeSyncer, l)
+	levels.base = atomicLevel.Level()
+	sinks := cfg.Sinks
+	if len(sinks) == 0 {
+		sinks = []config.SinkConfig{{
+			Output:     cfg.Filename,
+			Encoder:    "json",
+			MaxSize:    cfg.MaxSize,
+			MaxAge:     cfg.MaxAge,
+			MaxBackups: cfg.MaxBackups,
+		}}
+	}
+	cores := make([]zapcore.Core, 0, len(sinks))
+	audits := make([]zapcore.Core, 0, len(sinks))
+	for _, s := range sinks {
+		core, audit, err := newSinkCore(s)
+		if err != nil {
+			return err
+		}
+		cores = append(cores, core)
+		audits = append(audits, audit)
+	}
 
-	lg = zap.New(core, zap.AddCaller())
+	lg = zap.New(withBurstSampler(zapcore.NewTee(cores...), cfg.Sampling.Burst), zap.AddCaller())
+	auditLg = zap.New(zapcore.NewTee(audits...), zap.AddCaller())
 	zap.ReplaceGlobals(lg) // 替换zap包中全局的logger实例，后续在其他包中只需使用zap.L()调用即可
 	return
 }
 
-func getEncoder() zapcore.Encoder {
+// Sync 刷新缓冲的日志并关闭日志文件，进程退出前调用
+// stdout/stderr 不支持 fsync，对应的错误会被忽略
+func Sync() error {
+	var errs []error
+	if err := lg.Sync(); err != nil && !errors.Is(err, syscall.EINVAL) && !errors.Is(err, syscall.ENOTTY) {
+		errs = append(errs, err)
+	}
+	for _, f := range files {
+		errs = append(errs, f.Close())
+	}
+	files = nil
+	return errors.Join(errs...)
+}
+
+// OnConfigChange 配置热加载的订阅者，把新的 log.level 应用为基础日志级别
+func OnConfigChange(e config.Event) error {
+	if !e.LevelChanged() {
+		return nil
+	}
+	var l zapcore.Level
+	if err := l.UnmarshalText([]byte(e.New.Level)); err != nil {
+		return err
+	}
+	setBaseLevel(l, "config")
+	return nil
+}
+
+// getEncoder 按名称创建编码器：json（默认）、console、logfmt
+func getEncoder(name string, color bool) zapcore.Encoder {
 	encoderConfig := zap.NewProductionEncoderConfig()
 	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 	encoderConfig.TimeKey = "time"
 	encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
 	encoderConfig.EncodeDuration = zapcore.SecondsDurationEncoder
 	encoderConfig.EncodeCaller = zapcore.ShortCallerEncoder
-	return zapcore.NewJSONEncoder(encoderConfig)
+	switch name {
+	case "console":
+		if color {
+			encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		}
+		return zapcore.NewConsoleEncoder(encoderConfig)
+	case "logfmt":
+		return newLogfmtEncoder(encoderConfig)
+	default:
+		return zapcore.NewJSONEncoder(encoderConfig)
+	}
 }
 
 func getLogWriter(filename string, maxSize, maxBackup, maxAge int) zapcore.WriteSyncer {
@@ -51,74 +113,11 @@ func getLogWriter(filename string, maxSize, maxBackup, maxAge int) zapcore.Write
 		MaxBackups: maxBackup,
 		MaxAge:     maxAge,
 	}
+	files = append(files, lumberJackLogger)
 	return zapcore.AddSync(lumberJackLogger)
 }
 
 // GinLogger 接收gin框架默认的日志
 func GinLogger() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		start := time.Now()
-		path := c.Request.URL.Path
-		query := c.Request.URL.RawQuery
-		c.Next()
-
-		cost := time.Since(start)
-		lg.Info(path,
-			zap.Int("status", c.Writer.Status()),
-			zap.String("method", c.Request.Method),
-			zap.String("path", path),
-			zap.String("query", query),
-			zap.String("ip", c.ClientIP()),
-			zap.String("user-agent", c.Request.UserAgent()),
-			zap.String("errors", c.Errors.ByType(gin.ErrorTypePrivate).String()),
-			zap.Duration("cost", cost),
-		)
-	}
-}
-
-// GinRecovery recover掉项目可能出现的panic，并使用zap记录相关日志
-func GinRecovery(stack bool) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		defer func() {
-			if err := recover(); err != nil {
-				// Check for a broken connection, as it is not really a
-				// condition that warrants a panic stack trace.
-				var brokenPipe bool
-				if ne, ok := err.(*net.OpError); ok {
-					if se, ok := ne.Err.(*os.SyscallError); ok {
-						if strings.Contains(strings.ToLower(se.Error()), "broken pipe") || strings.Contains(strings.ToLower(se.Error()), "connection reset by peer") {
-							brokenPipe = true
-						}
-					}
-				}
-
-				httpRequest, _ := httputil.DumpRequest(c.Request, false)
-				if brokenPipe {
-					lg.Error(c.Request.URL.Path,
-						zap.Any("error", err),
-						zap.String("request", string(httpRequest)),
-					)
-					// If the connection is dead, we can't write a status to it.
-					c.Error(err.(error)) // nolint: errcheck
-					c.Abort()
-					return
-				}
-
-				if stack {
-					lg.Error("[Recovery from panic]",
-						zap.Any("error", err),
-						zap.String("request", string(httpRequest)),
-						zap.String("stack", string(debug.Stack())),
-					)
-				} else {
-					lg.Error("[Recovery from panic]",
-						zap.Any("error", err),
-						zap.String("request", string(httpRequest)),
-					)
-				}
-				c.AbortWithStatus(http.StatusInternalServerError)
-			}
-		}()
-		c.Next()
-	}
+	return GinLoggerWithConfig(LoggerConfig{})
 }
@@ -0,0 +1,231 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"syscall"
+	"time"
+
+	"gin_learn/gin_zap_demo/config"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// PanicInfo 一次panic的信息，传给 OnPanic 钩子
+type PanicInfo struct {
+	Value       any    // recover() 得到的值
+	Stack       []byte // 调用栈
+	RequestID   string
+	Method      string
+	Path        string
+	BrokenPipe  bool   // 客户端已断开连接
+	Fingerprint string // panic值的类型+调用位置的指纹，相同指纹视为同一个panic
+	Repeated    bool   // 在去重窗口内重复出现，日志中未记录调用栈
+}
+
+// PanicHook panic钩子，可用于告警、统计等；钩子自身的panic会被忽略
+type PanicHook func(c *gin.Context, info PanicInfo)
+
+// RecoveryConfig panic恢复配置
+type RecoveryConfig struct {
+	Stack       bool          // 是否记录调用栈
+	Redactor    Redactor      // 请求头和查询参数的脱敏器，为空时使用 DefaultRedactor
+	DedupWindow time.Duration // 相同panic在窗口内只记录一次调用栈，0 表示不去重
+	OnPanic     []PanicHook   // 每次panic都会依次调用
+}
+
+// NewRecoveryConfig 由 log.recovery 和 log.access 配置生成panic恢复配置，hooks 为 OnPanic 钩子
+func NewRecoveryConfig(cfg *config.LogConfig, hooks ...PanicHook) RecoveryConfig {
+	return RecoveryConfig{
+		Stack:       cfg.Recovery.Stack,
+		Redactor:    newRedactor(cfg.Access),
+		DedupWindow: cfg.Recovery.DedupWindow.Std(),
+		OnPanic:     hooks,
+	}
+}
+
+// Problem panic后返回给客户端的错误响应，格式参考 RFC 7807 (application/problem+json)
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// GinRecovery recover掉项目可能出现的panic，并使用zap记录相关日志
+func GinRecovery(stack bool) gin.HandlerFunc {
+	return GinRecoveryWithConfig(RecoveryConfig{Stack: stack})
+}
+
+// GinRecoveryWithConfig 同 GinRecovery，另外：
+//   - 日志中的请求信息经 Redactor 脱敏，不会泄露 Cookie/Authorization
+//   - 返回带 request_id 的 JSON 错误响应，而不是空的 500
+//   - 相同的panic在 DedupWindow 内只记录一次调用栈，并在下次记录时带上被省略的次数
+//   - 每次panic都会调用 OnPanic 钩子
+func GinRecoveryWithConfig(conf RecoveryConfig) gin.HandlerFunc {
+	if conf.Redactor == nil {
+		conf.Redactor = DefaultRedactor
+	}
+	dedup := &panicDedup{window: conf.DedupWindow, seen: make(map[string]*panicRecord)}
+	return func(c *gin.Context) {
+		defer func() {
+			if err := recover(); err != nil {
+				info := PanicInfo{
+					Value:       err,
+					Stack:       debug.Stack(),
+					RequestID:   RequestIDFrom(c),
+					Method:      c.Request.Method,
+					Path:        c.Request.URL.Path,
+					BrokenPipe:  isBrokenPipe(err),
+					Fingerprint: panicFingerprint(err),
+				}
+				suppressed, repeated, evicted := dedup.check(info.Fingerprint)
+				info.Repeated = repeated
+
+				httpRequest := dumpRequest(c.Request, conf.Redactor)
+				lg := FromContext(c)
+				// 过了窗口不再出现的panic从去重表中移除，被省略的次数补记一条
+				for fp, n := range evicted {
+					lg.Warn("[Recovery from panic] suppressed",
+						zap.String("fingerprint", fp),
+						zap.Int("suppressed", n),
+					)
+				}
+				switch {
+				case info.BrokenPipe:
+					// Check for a broken connection, as it is not really a
+					// condition that warrants a panic stack trace.
+					lg.Error(c.Request.URL.Path,
+						zap.Any("error", err),
+						zap.String("request", httpRequest),
+					)
+				case repeated:
+					lg.Debug("[Recovery from panic] repeated",
+						zap.Any("error", err),
+						zap.String("fingerprint", info.Fingerprint),
+					)
+				default:
+					fields := []zap.Field{
+						zap.Any("error", err),
+						zap.String("request", httpRequest),
+						zap.String("fingerprint", info.Fingerprint),
+					}
+					if suppressed > 0 {
+						fields = append(fields, zap.Int("suppressed", suppressed))
+					}
+					if conf.Stack {
+						fields = append(fields, zap.String("stack", string(info.Stack)))
+					}
+					lg.Error("[Recovery from panic]", fields...)
+				}
+
+				for _, hook := range conf.OnPanic {
+					runHook(lg, hook, c, info)
+				}
+
+				if info.BrokenPipe {
+					// If the connection is dead, we can't write a status to it.
+					if e, ok := err.(error); ok {
+						c.Error(e) // nolint: errcheck
+					}
+					c.Abort()
+					return
+				}
+				c.Header("Content-Type", "application/problem+json")
+				c.AbortWithStatusJSON(http.StatusInternalServerError, Problem{
+					Type:      "about:blank",
+					Title:     http.StatusText(http.StatusInternalServerError),
+					Status:    http.StatusInternalServerError,
+					Detail:    "an unexpected error occurred, please report the request_id",
+					RequestID: info.RequestID,
+				})
+			}
+		}()
+		c.Next()
+	}
+}
+
+// isBrokenPipe 客户端断开连接导致的写失败
+func isBrokenPipe(v any) bool {
+	err, ok := v.(error)
+	return ok && (errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET))
+}
+
+// panicFingerprint 由panic值的类型和panic发生处的调用链计算指纹；
+// 不使用panic值本身，否则 "index out of range [7]" 这类带变量的panic每次指纹都不同，去重失效
+func panicFingerprint(v any) string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	h := fnv.New64a()
+	_, _ = fmt.Fprintf(h, "%T", v)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		_, _ = fmt.Fprintf(h, "|%s:%d", f.Function, f.Line)
+		if !more {
+			break
+		}
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+func runHook(lg *zap.Logger, hook PanicHook, c *gin.Context, info PanicInfo) {
+	defer func() {
+		if err := recover(); err != nil {
+			lg.Error("panic hook failed", zap.Any("error", err))
+		}
+	}()
+	hook(c, info)
+}
+
+// panicDedup 相同指纹的panic在窗口内只完整记录一次
+type panicDedup struct {
+	mu     sync.Mutex
+	window time.Duration
+	seen   map[string]*panicRecord
+}
+
+type panicRecord struct {
+	logged     time.Time
+	suppressed int
+}
+
+// check 返回上次完整记录之后被省略的次数、本次是否应省略，以及过了窗口被移除的其他指纹和它们被省略的次数
+func (d *panicDedup) check(fp string) (suppressed int, repeated bool, evicted map[string]int) {
+	if d.window <= 0 {
+		return 0, false, nil
+	}
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for k, r := range d.seen {
+		if k == fp || now.Sub(r.logged) <= d.window {
+			continue
+		}
+		if r.suppressed > 0 {
+			if evicted == nil {
+				evicted = make(map[string]int)
+			}
+			evicted[k] = r.suppressed
+		}
+		delete(d.seen, k)
+	}
+	r, ok := d.seen[fp]
+	if ok && now.Sub(r.logged) <= d.window {
+		r.suppressed++
+		return 0, true, evicted
+	}
+	if !ok {
+		r = &panicRecord{}
+		d.seen[fp] = r
+	}
+	suppressed, r.suppressed, r.logged = r.suppressed, 0, now
+	return suppressed, false, evicted
+}
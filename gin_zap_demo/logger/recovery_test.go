@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestRecoveryDedup 同一位置、不同值的panic指纹相同；过了窗口不再出现的指纹被移除，并补记被省略的次数
+func TestRecoveryDedup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	core, logs := observer.New(zapcore.DebugLevel)
+	lg := zap.New(core)
+	var infos []PanicInfo
+	conf := RecoveryConfig{
+		DedupWindow: 50 * time.Millisecond,
+		OnPanic:     []PanicHook{func(_ *gin.Context, info PanicInfo) { infos = append(infos, info) }},
+	}
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set(LoggerKey, lg) }, GinRecoveryWithConfig(conf))
+	r.GET("/index", func(c *gin.Context) {
+		var s []int
+		i, _ := strconv.Atoi(c.Query("i"))
+		_ = s[i]
+	})
+	r.GET("/other", func(*gin.Context) { panic("other") })
+	// get 在新的 goroutine 中处理请求，与 net/http 一样每次的调用链相同，不受测试代码调用位置的影响
+	get := func(path string) {
+		w := httptest.NewRecorder()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		}()
+		<-done
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("GET %s = %d, want 500", path, w.Code)
+		}
+	}
+
+	get("/index?i=7")
+	get("/index?i=9")
+	get("/index?i=11")
+	if infos[0].Fingerprint != infos[1].Fingerprint || !infos[1].Repeated || !infos[2].Repeated {
+		t.Fatalf("panics at the same site with different values were not deduplicated: %s %s %s",
+			infos[0].Fingerprint, infos[1].Fingerprint, infos[2].Fingerprint)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	get("/other")
+	flushed := logs.FilterMessage("[Recovery from panic] suppressed").All()
+	if len(flushed) != 1 || flushed[0].ContextMap()["suppressed"] != int64(2) || flushed[0].ContextMap()["fingerprint"] != infos[0].Fingerprint {
+		t.Fatalf("suppressed flush = %v, want one entry with suppressed=2", logs.All())
+	}
+	// 被移除后再次出现按新的panic完整记录
+	get("/index?i=3")
+	if last := infos[len(infos)-1]; last.Fingerprint != infos[0].Fingerprint || last.Repeated {
+		t.Fatal("evicted fingerprint still treated as repeated")
+	}
+}
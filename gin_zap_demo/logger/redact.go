@@ -0,0 +1,209 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Redacted 脱敏后的占位值
+const Redacted = "[REDACTED]"
+
+// Redactor 字段脱敏器，访问日志和panic日志中的请求头、查询参数、请求/响应体都会经过它
+type Redactor interface {
+	// Redact 返回 key 对应取值脱敏后的结果，不需要脱敏时原样返回
+	Redact(key, value string) string
+}
+
+// RedactorFunc 函数形式的 Redactor
+type RedactorFunc func(key, value string) string
+
+func (f RedactorFunc) Redact(key, value string) string {
+	return f(key, value)
+}
+
+// DefaultSensitiveKeys 默认视为敏感信息的字段名（不区分大小写，包含即匹配）
+var DefaultSensitiveKeys = []string{
+	"password", "passwd", "secret", "token", "authorization", "cookie", "session", "api_key", "apikey",
+}
+
+// KeyRedactor 按字段名脱敏：字段名包含任一关键字时整个取值替换为 Redacted
+type KeyRedactor struct {
+	keys []string
+}
+
+// NewKeyRedactor 创建按字段名脱敏的 Redactor
+func NewKeyRedactor(keys ...string) *KeyRedactor {
+	r := &KeyRedactor{keys: make([]string, 0, len(keys))}
+	for _, k := range keys {
+		if k = strings.ToLower(strings.TrimSpace(k)); k != "" {
+			r.keys = append(r.keys, k)
+		}
+	}
+	return r
+}
+
+func (r *KeyRedactor) Redact(key, value string) string {
+	k := strings.ToLower(key)
+	for _, s := range r.keys {
+		if strings.Contains(k, s) {
+			return Redacted
+		}
+	}
+	return value
+}
+
+// DefaultRedactor 默认脱敏器
+var DefaultRedactor Redactor = NewKeyRedactor(DefaultSensitiveKeys...)
+
+// redactHeaders 按白名单/黑名单挑选请求头并脱敏；allow 中的 "*" 表示全部请求头
+func redactHeaders(h http.Header, allow, deny []string, r Redactor) map[string]string {
+	all := false
+	allowed := make(map[string]bool, len(allow))
+	for _, k := range allow {
+		if k == "*" {
+			all = true
+		}
+		allowed[http.CanonicalHeaderKey(k)] = true
+	}
+	denied := make(map[string]bool, len(deny))
+	for _, k := range deny {
+		denied[http.CanonicalHeaderKey(k)] = true
+	}
+
+	out := make(map[string]string)
+	for k, v := range h {
+		if denied[k] || !(all || allowed[k]) {
+			continue
+		}
+		out[k] = r.Redact(k, strings.Join(v, ", "))
+	}
+	return out
+}
+
+// redactQuery 对查询参数逐个脱敏
+func redactQuery(raw string, r Redactor) string {
+	if raw == "" {
+		return raw
+	}
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return redactText(raw, r)
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		for _, v := range values[k] {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			if red := r.Redact(k, v); red != v {
+				b.WriteString(url.QueryEscape(k) + "=" + red)
+			} else {
+				b.WriteString(url.QueryEscape(k) + "=" + url.QueryEscape(v))
+			}
+		}
+	}
+	return b.String()
+}
+
+// redactBody 按 Content-Type 对请求/响应体脱敏：JSON 和表单逐字段处理，
+// 被截断或无法解析的内容按 "key":"value" / key=value 模式尽量脱敏
+func redactBody(contentType string, body []byte, truncated bool, r Redactor) string {
+	var s string
+	switch {
+	case !truncated && strings.Contains(contentType, "json"):
+		var v any
+		if err := json.Unmarshal(body, &v); err != nil {
+			s = redactText(string(body), r)
+			break
+		}
+		b, _ := json.Marshal(redactJSON("", v, r))
+		s = string(b)
+	case !truncated && strings.Contains(contentType, "x-www-form-urlencoded"):
+		s = redactQuery(string(body), r)
+	default:
+		s = redactText(string(body), r)
+	}
+	if truncated {
+		s += "...(truncated)"
+	}
+	return s
+}
+
+func redactJSON(key string, v any, r Redactor) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, sub := range t {
+			t[k] = redactJSON(k, sub, r)
+		}
+		return t
+	case []any:
+		for i, sub := range t {
+			t[i] = redactJSON(key, sub, r)
+		}
+		return t
+	case nil:
+		return t
+	default:
+		s := fmt.Sprint(t)
+		if red := r.Redact(key, s); red != s {
+			return red
+		}
+		return t
+	}
+}
+
+var (
+	jsonPairRe = regexp.MustCompile(`"([^"\\]+)"(\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\]\s]+)`)
+	formPairRe = regexp.MustCompile(`([\w.\-\[\]]+)=([^&\s]*)`)
+)
+
+// redactText 对无法结构化解析的文本做模式匹配脱敏
+func redactText(s string, r Redactor) string {
+	s = jsonPairRe.ReplaceAllStringFunc(s, func(m string) string {
+		sub := jsonPairRe.FindStringSubmatch(m)
+		val := strings.Trim(sub[3], `"`)
+		if red := r.Redact(sub[1], val); red != val {
+			return `"` + sub[1] + `"` + sub[2] + `"` + red + `"`
+		}
+		return m
+	})
+	return formPairRe.ReplaceAllStringFunc(s, func(m string) string {
+		sub := formPairRe.FindStringSubmatch(m)
+		if red := r.Redact(sub[1], sub[2]); red != sub[2] {
+			return sub[1] + "=" + red
+		}
+		return m
+	})
+}
+
+// dumpRequest 输出请求行和全部请求头，用于panic日志；敏感请求头和查询参数会被脱敏
+func dumpRequest(req *http.Request, r Redactor) string {
+	var b bytes.Buffer
+	uri := req.URL.Path
+	if req.URL.RawQuery != "" {
+		uri += "?" + redactQuery(req.URL.RawQuery, r)
+	}
+	fmt.Fprintf(&b, "%s %s %s\r\n", req.Method, uri, req.Proto)
+	fmt.Fprintf(&b, "Host: %s\r\n", req.Host)
+	headers := redactHeaders(req.Header, []string{"*"}, nil, r)
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s: %s\r\n", k, headers[k])
+	}
+	return b.String()
+}
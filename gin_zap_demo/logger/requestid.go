@@ -0,0 +1,82 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// HeaderRequestID 请求ID使用的请求头/响应头
+const HeaderRequestID = "X-Request-ID"
+
+// gin.Context 中保存请求ID和请求级logger使用的key
+const (
+	RequestIDKey = "request_id"
+	LoggerKey    = "logger"
+)
+
+// ctxKey context.Context 中保存请求级logger使用的key
+type ctxKey struct{}
+
+// maxRequestIDLen 接受客户端传入的请求ID的最大长度
+const maxRequestIDLen = 64
+
+// RequestID 接收或生成 X-Request-ID，写回响应头，
+// 并把带 request_id 字段的子logger存入 gin.Context 和 Request 的 context.Context，
+// 之后 GinLogger、GinRecovery 以及业务代码中 FromContext(c) 输出的日志都带有同一个 request_id
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(HeaderRequestID)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Header(HeaderRequestID, id)
+
+		l := lg.With(zap.String(RequestIDKey, id))
+		c.Set(RequestIDKey, id)
+		c.Set(LoggerKey, l)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), ctxKey{}, l))
+		c.Next()
+	}
+}
+
+// FromContext 返回请求级logger；ctx 可以是 *gin.Context 或 c.Request.Context()，未经过 RequestID 中间件时返回全局logger
+func FromContext(ctx context.Context) *zap.Logger {
+	if c, ok := ctx.(*gin.Context); ok {
+		if l, ok := c.Value(LoggerKey).(*zap.Logger); ok {
+			return l
+		}
+		ctx = c.Request.Context()
+	}
+	if l, ok := ctx.Value(ctxKey{}).(*zap.Logger); ok {
+		return l
+	}
+	return zap.L()
+}
+
+// RequestIDFrom 返回当前请求的请求ID，不存在时返回空字符串
+func RequestIDFrom(c *gin.Context) string {
+	return c.GetString(RequestIDKey)
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID 只接受长度合适的可见 ASCII 字符，避免日志注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
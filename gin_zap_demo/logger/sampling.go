@@ -0,0 +1,142 @@
+package logger
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gin_learn/gin_zap_demo/config"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap/zapcore"
+)
+
+// AccessSampler 访问日志采样器，决定一次请求是否写访问日志，并统计丢弃的数量
+type AccessSampler struct {
+	slow         time.Duration
+	alwaysErrors bool
+	rules        []*sampleRule
+
+	logged  atomic.Uint64
+	skipped atomic.Uint64
+	sampled atomic.Uint64
+}
+
+type sampleRule struct {
+	path    string
+	prefix  bool
+	skip    bool
+	every   uint64
+	seen    atomic.Uint64
+	dropped atomic.Uint64
+}
+
+// activeSampler 最近创建的采样器，供 /admin/logstats 查询
+var activeSampler atomic.Pointer[AccessSampler]
+
+// burst 计数：zap 突发限流采样器的记录/丢弃数量
+var burstLogged, burstDropped atomic.Uint64
+
+// NewAccessSampler 由 log.sampling 配置创建采样器
+func NewAccessSampler(cfg *config.SamplingConfig) *AccessSampler {
+	s := &AccessSampler{slow: cfg.SlowThreshold.Std(), alwaysErrors: cfg.AlwaysErrors}
+	for _, r := range cfg.Rules {
+		rule := &sampleRule{path: r.Path, skip: r.Skip, every: uint64(max(r.Every, 1))}
+		if strings.HasSuffix(r.Path, "*") {
+			rule.path, rule.prefix = strings.TrimSuffix(r.Path, "*"), true
+		}
+		s.rules = append(s.rules, rule)
+	}
+	activeSampler.Store(s)
+	return s
+}
+
+// Keep 判断本次请求是否记录访问日志；s 为 nil 时全部记录
+func (s *AccessSampler) Keep(path string, status int, cost time.Duration) bool {
+	if s == nil {
+		return true
+	}
+	rule := s.match(path)
+	if rule == nil {
+		s.logged.Add(1)
+		return true
+	}
+	n := rule.seen.Add(1)
+	forced := (s.alwaysErrors && status >= http.StatusBadRequest) || (s.slow > 0 && cost >= s.slow)
+	switch {
+	case forced:
+	case rule.skip:
+		rule.dropped.Add(1)
+		s.skipped.Add(1)
+		return false
+	case (n-1)%rule.every != 0:
+		rule.dropped.Add(1)
+		s.sampled.Add(1)
+		return false
+	}
+	s.logged.Add(1)
+	return true
+}
+
+func (s *AccessSampler) match(path string) *sampleRule {
+	for _, r := range s.rules {
+		if (r.prefix && strings.HasPrefix(path, r.path)) || (!r.prefix && path == r.path) {
+			return r
+		}
+	}
+	return nil
+}
+
+// RuleStats 单条采样规则的统计
+type RuleStats struct {
+	Path    string `json:"path"`
+	Seen    uint64 `json:"seen"`
+	Dropped uint64 `json:"dropped"`
+}
+
+// SamplingStats 采样统计
+type SamplingStats struct {
+	Logged       uint64      `json:"logged"`        // 写出的访问日志条数
+	Skipped      uint64      `json:"skipped"`       // 被 skip 规则丢弃的条数
+	Sampled      uint64      `json:"sampled"`       // 被 1/N 采样丢弃的条数
+	Rules        []RuleStats `json:"rules"`         // 各规则的明细
+	BurstLogged  uint64      `json:"burst_logged"`  // 经过 zap 突发限流后保留的条数
+	BurstDropped uint64      `json:"burst_dropped"` // 被 zap 突发限流丢弃的条数
+}
+
+// Stats 返回采样统计；s 为 nil 时只包含突发限流的统计
+func (s *AccessSampler) Stats() SamplingStats {
+	st := SamplingStats{BurstLogged: burstLogged.Load(), BurstDropped: burstDropped.Load()}
+	if s == nil {
+		return st
+	}
+	st.Logged, st.Skipped, st.Sampled = s.logged.Load(), s.skipped.Load(), s.sampled.Load()
+	for _, r := range s.rules {
+		path := r.path
+		if r.prefix {
+			path += "*"
+		}
+		st.Rules = append(st.Rules, RuleStats{Path: path, Seen: r.seen.Load(), Dropped: r.dropped.Load()})
+	}
+	return st
+}
+
+// withBurstSampler 按 log.sampling.burst 配置为 core 加上 zap 的突发限流，并统计记录/丢弃数量
+func withBurstSampler(core zapcore.Core, cfg config.BurstConfig) zapcore.Core {
+	if cfg.Tick <= 0 {
+		return core
+	}
+	return zapcore.NewSamplerWithOptions(core, cfg.Tick.Std(), cfg.First, cfg.Thereafter,
+		zapcore.SamplerHook(func(_ zapcore.Entry, dec zapcore.SamplingDecision) {
+			if dec&zapcore.LogDropped != 0 {
+				burstDropped.Add(1)
+			} else {
+				burstLogged.Add(1)
+			}
+		}))
+}
+
+func getLogStats(c *gin.Context) {
+	c.JSON(http.StatusOK, activeSampler.Load().Stats())
+}
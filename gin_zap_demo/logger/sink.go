@@ -0,0 +1,125 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gin_learn/gin_zap_demo/config"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// newSinkCore 按 sink 配置创建 core：编码器 + 输出 + 级别范围；
+// audit 与 core 写到同一个输出，但不受 atomicLevel 限制，用于必须输出的审计日志（如修改日志级别）
+func newSinkCore(s config.SinkConfig) (core, audit zapcore.Core, err error) {
+	lo, hi, err := levelRange(s.MinLevel, s.MaxLevel)
+	if err != nil {
+		return nil, nil, err
+	}
+	var ws zapcore.WriteSyncer
+	switch s.Output {
+	case "stdout":
+		ws = zapcore.Lock(os.Stdout)
+	case "stderr":
+		ws = zapcore.Lock(os.Stderr)
+	default:
+		ws = getLogWriter(s.Output, s.MaxSize, s.MaxBackups, s.MaxAge)
+	}
+	core = zapcore.NewCore(getEncoder(s.Encoder, s.Color), ws, zap.LevelEnablerFunc(func(l zapcore.Level) bool {
+		return atomicLevel.Enabled(l) && l >= lo && l <= hi
+	}))
+	audit = zapcore.NewCore(getEncoder(s.Encoder, s.Color), ws, zap.LevelEnablerFunc(func(l zapcore.Level) bool {
+		return l >= lo && l <= hi
+	}))
+	return core, audit, nil
+}
+
+// levelRange 解析 sink 的级别范围 [min, max]，为空时不限制
+func levelRange(min, max string) (zapcore.Level, zapcore.Level, error) {
+	lo, hi := zapcore.DebugLevel, zapcore.FatalLevel
+	if min != "" {
+		if err := lo.UnmarshalText([]byte(min)); err != nil {
+			return lo, hi, err
+		}
+	}
+	if max != "" {
+		if err := hi.UnmarshalText([]byte(max)); err != nil {
+			return lo, hi, err
+		}
+	}
+	return lo, hi, nil
+}
+
+// logfmtEncoder 以 key=value 形式输出日志
+// 先用 JSON 编码器编码，再按原有字段顺序转换为 logfmt，嵌套对象保留为 JSON 字符串
+type logfmtEncoder struct {
+	zapcore.Encoder
+}
+
+func newLogfmtEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
+	cfg.LineEnding = zapcore.DefaultLineEnding
+	return logfmtEncoder{zapcore.NewJSONEncoder(cfg)}
+}
+
+func (e logfmtEncoder) Clone() zapcore.Encoder {
+	return logfmtEncoder{e.Encoder.Clone()}
+}
+
+func (e logfmtEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	buf, err := e.Encoder.EncodeEntry(ent, fields)
+	if err != nil {
+		return nil, err
+	}
+	defer buf.Free()
+
+	dec := json.NewDecoder(bytes.NewReader(buf.Bytes()))
+	dec.UseNumber()
+	if _, err = dec.Token(); err != nil { // {
+		return nil, err
+	}
+	out := bufferPool.Get()
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			out.Free()
+			return nil, err
+		}
+		var raw json.RawMessage
+		if err = dec.Decode(&raw); err != nil {
+			out.Free()
+			return nil, err
+		}
+		if out.Len() > 0 {
+			out.AppendByte(' ')
+		}
+		out.AppendString(fmt.Sprint(tok))
+		out.AppendByte('=')
+		out.AppendString(logfmtValue(raw))
+	}
+	out.AppendString(zapcore.DefaultLineEnding)
+	return out, nil
+}
+
+// logfmtValue 字符串在含空白、引号或=时加引号，其余值原样输出
+func logfmtValue(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		v := string(raw)
+		if len(v) > 0 && (v[0] == '{' || v[0] == '[') {
+			return strconv.Quote(v)
+		}
+		return v
+	}
+	if s == "" || strings.ContainsAny(s, " \t\r\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+var bufferPool = buffer.NewPool()
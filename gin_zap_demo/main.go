@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"gin_learn/gin_zap_demo/config"
 	"gin_learn/gin_zap_demo/logger"
+	"gin_learn/gin_zap_demo/server"
 	"net/http"
 	"os"
 
@@ -14,15 +17,38 @@ import (
 
 /*
 启动项目CMD： go run ./gin_zap_demo/main.go ./gin_zap_demo/config.json
+配置按 默认值 < 配置文件(json/yaml/toml) < GIN_DEMO_* 环境变量 < 命令行参数 的顺序叠加，例如：
+
+	GIN_DEMO_PORT=9090 go run ./gin_zap_demo/main.go --config ./gin_zap_demo/config.json --log.level=info
+
+查看最终配置及每个值的来源：
+
+	go run ./gin_zap_demo/main.go ./gin_zap_demo/config.json --print-config
+
+CI 中校验配置（有问题时逐条输出并以非0状态码退出）：
+
+	go run ./gin_zap_demo/main.go check-config ./gin_zap_demo/config.json
 */
 func main() {
-	// load config from config.json
-	if len(os.Args) < 1 {
+	// load config: defaults < file < env < flags
+	flags, err := config.ParseFlags(os.Args[0], os.Args[1:])
+	if err == flag.ErrHelp {
 		return
 	}
-
-	if err := config.Init(os.Args[1]); err != nil {
-		panic(err)
+	if err != nil {
+		os.Exit(2)
+	}
+	if err := config.InitWithFlags(flags); err != nil {
+		fmt.Fprintf(os.Stderr, "init config failed, err:%v\n", err)
+		os.Exit(1)
+	}
+	if flags.Command == config.CommandCheckConfig {
+		fmt.Println("config ok")
+		return
+	}
+	if flags.PrintConfig {
+		_ = config.Conf.Print(os.Stdout)
+		return
 	}
 	// init logger
 	if err := logger.InitLogger(config.Conf.LogConfig); err != nil {
@@ -32,9 +58,37 @@ func main() {
 
 	gin.SetMode(config.Conf.Mode)
 
+	// 监听配置文件，运行时修改 log.level / mode 无需重启
+	config.Subscribe(logger.OnConfigChange)
+	config.Subscribe(func(e config.Event) error {
+		if e.ModeChanged() {
+			gin.SetMode(e.New.Mode)
+		}
+		return nil
+	})
+	if flags.File != "" {
+		stopWatch, err := config.Watch(flags.File)
+		if err != nil {
+			zap.L().Error("watch config failed", zap.Error(err))
+		} else {
+			defer stopWatch()
+		}
+	}
+
 	r := gin.Default()
-	// 注册zap相关中间件
-	r.Use(logger.GinLogger(), logger.GinRecovery(true))
+	// 注册zap相关中间件，RequestID 需放在最前面，后续日志才能带上 request_id
+	// log.access 可配置记录请求头、请求/响应体，password/token/cookie 等敏感字段会被脱敏
+	// log.sampling 可按路径跳过或采样访问日志，丢弃统计见 GET /admin/logstats
+	accessConf := logger.NewLoggerConfig(config.Conf.LogConfig)
+	// log.recovery 控制panic日志是否带调用栈及相同panic的去重窗口，可传入 OnPanic 钩子对接告警/监控
+	r.Use(
+		logger.RequestID(),
+		logger.GinLoggerWithConfig(accessConf),
+		logger.GinRecoveryWithConfig(logger.NewRecoveryConfig(config.Conf.LogConfig)),
+	)
+	// 运行时查看/修改日志级别，需配置 admin.token（或环境变量 GIN_DEMO_ADMIN_TOKEN）
+	// curl -X PUT localhost:8080/admin/loglevel -H 'Authorization: Bearer <token>' -d '{"level":"debug","ttl":"10m"}'
+	logger.RegisterAdmin(r)
 
 	r.GET("/hello", func(c *gin.Context) {
 		// 假设你有一些数据需要记录到日志中
@@ -42,12 +96,24 @@ func main() {
 			name = "kjh"
 			age  = 18
 		)
-		// 记录日志并使用zap.Xxx(key, val)记录相关字段
-		zap.L().Debug("this is hello func", zap.String("user", name), zap.Int("age", age))
+		// 记录日志并使用zap.Xxx(key, val)记录相关字段，FromContext 返回的logger自动带上 request_id
+		logger.FromContext(c).Debug("this is hello func", zap.String("user", name), zap.Int("age", age))
 
 		c.String(http.StatusOK, "hello ~")
 	})
 
+	// 收到 SIGINT/SIGTERM 后 /readyz 先返回 503，再等待在途请求完成（最长 server.drain_timeout），最后刷新并关闭日志文件
 	addr := fmt.Sprintf(":%v", config.Conf.Port)
-	r.Run(addr)
+	srv := server.New(r, server.Options{
+		Addr:         addr,
+		DrainTimeout: config.Conf.Server.DrainTimeout.Std(),
+		ReadyDelay:   config.Conf.Server.ReadyDelay.Std(),
+		OnShutdown: []func(ctx context.Context) error{
+			func(ctx context.Context) error { return logger.Sync() },
+		},
+	})
+	srv.RegisterHealth(r)
+	if err := srv.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "server exited with error: %v\n", err)
+	}
 }
@@ -0,0 +1,145 @@
+package server
+
+/*
+http.Server 生命周期：启动 -> 收到 SIGINT/SIGTERM -> 置为未就绪 -> 等待 ReadyDelay（留给负载均衡摘除流量）
+-> Shutdown 等待在途请求完成（最长 DrainTimeout，超时后强制关闭连接）-> 依次执行 OnShutdown 清理函数。
+
+各个 demo 的 main 中把 r.Run(addr) 换成 server.Run(r, server.Options{Addr: addr}) 即可复用。
+*/
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// DefaultDrainTimeout 默认等待在途请求完成的最长时间
+const DefaultDrainTimeout = 10 * time.Second
+
+// Options 服务配置
+type Options struct {
+	Addr         string
+	DrainTimeout time.Duration                     // 等待在途请求完成的最长时间，0 表示使用 DefaultDrainTimeout
+	ReadyDelay   time.Duration                     // 置为未就绪后、开始关闭前的等待时间
+	Logger       *zap.Logger                       // 为空时使用 zap.L()
+	OnShutdown   []func(ctx context.Context) error // 服务停止后依次执行，例如刷新日志
+}
+
+// Server 带优雅退出的 http 服务
+type Server struct {
+	srv   *http.Server
+	opts  Options
+	ready atomic.Bool
+}
+
+// New 创建服务，handler 通常是 *gin.Engine
+func New(handler http.Handler, opts Options) *Server {
+	if opts.DrainTimeout <= 0 {
+		opts.DrainTimeout = DefaultDrainTimeout
+	}
+	if opts.Logger == nil {
+		opts.Logger = zap.L()
+	}
+	return &Server{srv: &http.Server{Addr: opts.Addr, Handler: handler}, opts: opts}
+}
+
+// Run 创建服务并运行到收到退出信号为止
+func Run(handler http.Handler, opts Options) error {
+	return New(handler, opts).Run()
+}
+
+// Ready 服务是否就绪；收到退出信号后立即变为 false
+func (s *Server) Ready() bool {
+	return s.ready.Load()
+}
+
+// RegisterHealth 注册存活检查 /healthz 和就绪检查 /readyz（排空期间返回 503）
+func (s *Server) RegisterHealth(r gin.IRouter) {
+	r.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+	r.GET("/readyz", func(c *gin.Context) {
+		if !s.Ready() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "draining"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ready"})
+	})
+}
+
+// Run 启动服务并阻塞，收到 SIGINT/SIGTERM 后优雅退出
+func (s *Server) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return s.RunContext(ctx)
+}
+
+// RunContext 启动服务并阻塞，ctx 结束后优雅退出
+func (s *Server) RunContext(ctx context.Context) error {
+	lg := s.opts.Logger
+	addr := s.srv.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	// 先监听端口再标记就绪：端口被占用等错误直接返回，/readyz 不会短暂报告就绪
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		lg.Error("server failed", zap.Error(err))
+		return errors.Join(err, s.cleanup())
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.srv.Serve(ln)
+	}()
+	s.ready.Store(true)
+	lg.Info("server started", zap.String("addr", ln.Addr().String()))
+
+	select {
+	case err := <-errCh:
+		s.ready.Store(false)
+		if !errors.Is(err, http.ErrServerClosed) {
+			lg.Error("server failed", zap.Error(err))
+			return errors.Join(err, s.cleanup())
+		}
+		return s.cleanup()
+	case <-ctx.Done():
+	}
+
+	s.ready.Store(false)
+	lg.Info("shutting down, server marked not ready",
+		zap.Duration("ready_delay", s.opts.ReadyDelay), zap.Duration("drain_timeout", s.opts.DrainTimeout))
+	if s.opts.ReadyDelay > 0 {
+		time.Sleep(s.opts.ReadyDelay)
+	}
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), s.opts.DrainTimeout)
+	defer cancel()
+	err = s.srv.Shutdown(drainCtx)
+	if err != nil {
+		lg.Warn("drain timeout exceeded, closing remaining connections", zap.Error(err))
+		_ = s.srv.Close()
+	} else {
+		lg.Info("server stopped, all requests drained")
+	}
+	return errors.Join(err, s.cleanup())
+}
+
+// cleanup 依次执行 OnShutdown，单个失败不影响后续
+func (s *Server) cleanup() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.DrainTimeout)
+	defer cancel()
+	var errs []error
+	for _, fn := range s.opts.OnShutdown {
+		errs = append(errs, fn(ctx))
+	}
+	return errors.Join(errs...)
+}
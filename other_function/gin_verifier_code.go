@@ -10,112 +10,128 @@ web端是怎么实现验证码的功能呢？
 - 前端将图片中的内容发送给后端，后端根据session中的k取得v，比对校验。如果通过继续下一步的逻辑，失败给出错误提示
 
 API接口验证码实现方式类似，可以把键值对存储在起来，验证的时候把键值对传输过来一起校验。
+（POST /captcha 返回验证码ID和 base64 图片，不依赖 Cookie，供 SPA / 移动端使用，见 verifier/api.go）
+
+验证码的生成和校验在 other_function/verifier 包中，登录接口的防暴力破解也复用了它。
+
+dchest/captcha 默认把答案保存在进程内存中，多副本部署时在 A 上生成的验证码无法在 B 上校验。
+这里通过 captcha.SetCustomStore 换成 kv 存储（与 Session 相同，由 SESSION_BACKEND 等环境变量选择 memory/file/bolt/redis），
+答案在服务端按 CAPTCHA_EXPIRATION 过期，每个验证码最多答错 CAPTCHA_MAX_ATTEMPTS 次，答对即作废，不能重放。
+
+图片/语音验证码对视障用户和自动化的第一方客户端并不友好，这里同时提供工作量证明（hashcash，见 verifier/pow.go）：
+客户端 POST /pow/challenge 取得签名的 challenge，算出使 sha256(challenge:solution) 前 difficulty 位为 0 的 solution 后提交，
+难度由服务端按申请频率调整。浏览器直接引入 /pow/solver.js（打开 /pow 查看示例）。
 */
 
 import (
-	"bytes"
+	"context"
+	"expvar"
+	"gin_learn/gin_zap_demo/server"
+	"gin_learn/other_function/verifier"
+	"gin_learn/session_control/session"
 	"net/http"
-	"time"
 
-	"github.com/dchest/captcha"
-	"github.com/gin-contrib/sessions"
-	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
 )
 
-// 中间件，处理session
-func Session(keyPairs string) gin.HandlerFunc {
-	store := SessionConfig()
-	return sessions.Sessions(keyPairs, store)
-}
-
-func SessionConfig() sessions.Store {
-	sessionMaxAge := 3600
-	sessionSecret := "topgoer"
-	var store sessions.Store
-	store = cookie.NewStore([]byte(sessionSecret))
-	store.Options(sessions.Options{
-		MaxAge: sessionMaxAge, //seconds
-		Path:   "/",
-	})
-	return store
-}
-
-func Captcha(c *gin.Context, length ...int) {
-	l := captcha.DefaultLen
-	w, h := 107, 36
-	if len(length) == 1 {
-		l = length[0]
-	}
-	if len(length) == 2 {
-		w = length[1]
-	}
-	if len(length) == 3 {
-		h = length[2]
+func main() {
+	store, err := verifier.SessionConfig()
+	if err != nil {
+		panic("加载Session密钥失败: " + err.Error())
 	}
-	captchaId := captcha.NewLen(l)
-	session := sessions.Default(c)
-	session.Set("captcha", captchaId)
-	_ = session.Save()
-	_ = Serve(c.Writer, c.Request, captchaId, ".png", "zh", false, w, h)
-}
-
-func CaptchaVerify(c *gin.Context, code string) bool {
-	session := sessions.Default(c)
-	if captchaId := session.Get("captcha"); captchaId != nil {
-		session.Delete("captcha")
-		_ = session.Save()
-		if captcha.VerifyString(captchaId.(string), code) {
-			return true
-		} else {
-			return false
-		}
-	} else {
-		return false
+	captchaStore, err := verifier.OpenStore()
+	if err != nil {
+		panic("打开验证码存储失败: " + err.Error())
 	}
-}
-
-func Serve(w http.ResponseWriter, r *http.Request, id, ext, lang string, download bool, width, height int) error {
-	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
-	w.Header().Set("Pragma", "no-cache")
-	w.Header().Set("Expires", "0")
-
-	var content bytes.Buffer
-	switch ext {
-	case ".png":
-		w.Header().Set("Content-Type", "image/png")
-		_ = captcha.WriteImage(&content, id, width, height)
-	case ".wav":
-		w.Header().Set("Content-Type", "audio/x-wav")
-		_ = captcha.WriteAudio(&content, id, lang)
-	default:
-		return captcha.ErrNotFound
+	verifier.UseStore(captchaStore)
+	cfg, err := session.LoadConfig("")
+	if err != nil {
+		panic("加载Session配置失败: " + err.Error())
 	}
-
-	if download {
-		w.Header().Set("Content-Type", "application/octet-stream")
+	keyring, err := session.LoadKeyring(cfg.Keyring)
+	if err != nil {
+		panic("加载Session密钥失败: " + err.Error())
 	}
-	http.ServeContent(w, r, id+ext, time.Time{}, bytes.NewReader(content.Bytes()))
-	return nil
-}
+	// 工作量证明，challenge 用密钥环为 PoW 派生的密钥签名，未使用的 challenge 保存在验证码存储中
+	pow := verifier.NewPoW(keyring.SigningKey(session.PurposePoW), nil)
 
-func main() {
 	router := gin.Default()
 	router.LoadHTMLGlob("./other_function/*.html")
-	router.Use(Session("topgoer"))
-	router.GET("/captcha", func(c *gin.Context) {
-		Captcha(c, 4)
-	})
+	router.Use(verifier.Session("topgoer", store))
+	// 验证码参数（位数、尺寸、干扰、语音语言、有效期）见 verifier.CaptchaOptions，可按路由选用预设
+	captchaOpts := verifier.SMSCaptcha
+	router.GET("/captcha", verifier.CaptchaHandler(captchaOpts))
 	router.GET("/", func(c *gin.Context) {
 		c.HTML(http.StatusOK, "index.html", nil)
 	})
-	router.GET("/captcha/verify/:value", func(c *gin.Context) {
-		value := c.Param("value")
-		if CaptchaVerify(c, value) {
-			c.JSON(http.StatusOK, gin.H{"status": 0, "msg": "success"})
-		} else {
-			c.JSON(http.StatusOK, gin.H{"status": 1, "msg": "failed"})
+
+	// 无状态验证码接口：客户端自己保存 captcha_id，不使用 Session
+	// curl -X POST http://127.0.0.1:8080/captcha
+	router.POST("/captcha", verifier.Create(captchaOpts))
+	router.GET("/captcha/:file", verifier.Media(captchaOpts)) // <captcha_id>.png / <captcha_id>.wav?lang=en
+
+	// 校验验证码：答案放在请求体中（放在 URL 里会被记进访问日志），按 IP 和验证码 ID 限流，超过返回 429；
+	// 答错返回 403 并附带一个新的验证码。不带 captcha_id 时校验 GET /captcha 写入 Session 的验证码
+	// curl -X POST http://127.0.0.1:8080/captcha/verify -d '{"captcha_id":"<captcha_id>","answer":"1234"}'
+	// curl -X POST http://127.0.0.1:8080/captcha/verify -b cookie.txt -d '{"answer":"1234"}'
+	router.POST("/captcha/verify", verifier.VerifyHandler(captchaOpts, verifier.DefaultVerifyLimits))
+	// 校验成功/失败次数（expvar）：curl http://127.0.0.1:8080/debug/vars
+	router.GET("/debug/vars", gin.WrapH(expvar.Handler()))
+	// 工作量证明：取 challenge、浏览器端求解脚本和示例页面
+	// curl -X POST http://127.0.0.1:8080/pow/challenge
+	router.POST("/pow/challenge", pow.ChallengeHandler(verifier.DefaultVerifyLimits))
+	router.GET("/pow/solver.js", verifier.SolverHandler)
+	router.GET("/pow", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(powDemo))
+	})
+	// 需要验证码的接口挂 RequireChallenge，验证码和工作量证明提交其一即可，如发送短信；与 /captcha/verify 使用相同的限流
+	// curl -X POST http://127.0.0.1:8080/sms/send -H 'X-Captcha-Id: <captcha_id>' -H 'X-Captcha-Answer: 1234' -d '{"phone":"13800000000"}'
+	// curl -X POST http://127.0.0.1:8080/sms/send -H 'X-Pow-Challenge: <challenge>' -H 'X-Pow-Solution: <solution>' -d '{"phone":"13800000000"}'
+	router.POST("/sms/send", verifier.RequireChallenge(pow, verifier.DefaultVerifyLimits), func(c *gin.Context) {
+		var req struct {
+			Phone string `json:"phone" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+			return
 		}
+		c.JSON(http.StatusOK, gin.H{"status": 0, "msg": "短信已发送", "phone": req.Phone})
 	})
-	router.Run(":8080")
+	// 与 r.Run 相同，另外在 Ctrl+C 后等待在途请求处理完再退出
+	opts := server.Options{
+		Addr:       ":8080",
+		OnShutdown: []func(context.Context) error{func(context.Context) error { return captchaStore.Close() }},
+	}
+	if err := server.Run(router, opts); err != nil {
+		panic("Gin服务启动失败: " + err.Error())
+	}
 }
+
+// powDemo 用工作量证明发送短信的示例页面，不需要识别图片或收听语音
+const powDemo = `<!DOCTYPE html>
+<html lang="zh">
+<head><meta charset="utf-8"><title>工作量证明</title></head>
+<body>
+<form id="sms">
+  <label>手机号 <input name="phone" value="13800000000"></label>
+  <button type="submit">发送短信</button>
+  <p role="status" id="status"></p>
+</form>
+<script src="/pow/solver.js"></script>
+<script>
+document.getElementById("sms").addEventListener("submit", async (e) => {
+  e.preventDefault();
+  const status = document.getElementById("status");
+  status.textContent = "正在验证…";
+  const pow = await Pow.fetchAndSolve("/pow/challenge", (n) => { status.textContent = "正在验证… " + n; });
+  const resp = await fetch("/sms/send", {
+    method: "POST",
+    headers: {"Content-Type": "application/json", "X-Pow-Challenge": pow.challenge, "X-Pow-Solution": pow.solution},
+    body: JSON.stringify({phone: e.target.phone.value}),
+  });
+  status.textContent = JSON.stringify(await resp.json());
+});
+</script>
+</body>
+</html>
+`
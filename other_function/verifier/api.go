@@ -0,0 +1,216 @@
+package verifier
+
+/*
+无状态的验证码接口，给不使用 Cookie 的 SPA / 移动端：验证码 ID 由客户端保存，校验时和答案一起提交。
+
+	POST /captcha             -> {"captcha_id","image_base64","audio_url","expires_at"}
+	GET  /captcha/<id>.png    图片（刷新页面时重新获取）
+	GET  /captcha/<id>.wav    语音
+	POST /captcha/verify      {"captcha_id","answer"}，见 VerifyHandler
+
+需要验证码的接口挂 RequireCaptcha(limits)，客户端在请求头 X-Captcha-Id / X-Captcha-Answer，
+或请求体（JSON / 表单）字段 captcha_id / captcha_answer 中提交；
+同时接受工作量证明（pow.go）的接口挂 RequireChallenge(pow, limits)。
+*/
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dchest/captcha"
+	"github.com/gin-gonic/gin"
+)
+
+// 提交验证码的请求头
+const (
+	HeaderCaptchaID     = "X-Captcha-Id"
+	HeaderCaptchaAnswer = "X-Captcha-Answer"
+)
+
+// APIResponse POST /captcha 的响应
+type APIResponse struct {
+	CaptchaID   string    `json:"captcha_id"`
+	ImageBase64 string    `json:"image_base64"` // PNG
+	AudioURL    string    `json:"audio_url"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// Create 按 opts 生成验证码并以 JSON 返回 ID 和图片，不使用 Session；audio_url 相对于当前请求路径。opts 不合法时 panic
+func Create(opts CaptchaOptions) gin.HandlerFunc {
+	opts = opts.mustValidate()
+	return func(c *gin.Context) {
+		resp, err := newCaptcha(c, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成验证码失败"})
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// newCaptcha 生成验证码和 POST /captcha 的响应；audio_url 按当前路由所在的验证码路径拼接
+func newCaptcha(c *gin.Context, opts CaptchaOptions) (APIResponse, error) {
+	id := opts.newID()
+	var img bytes.Buffer
+	if err := opts.writeImage(&img, id); err != nil {
+		return APIResponse{}, err
+	}
+	base := strings.TrimSuffix(strings.TrimSuffix(c.Request.URL.Path, "/"), "/verify")
+	return APIResponse{
+		CaptchaID:   id,
+		ImageBase64: base64.StdEncoding.EncodeToString(img.Bytes()),
+		AudioURL:    base + "/" + id + ".wav",
+		ExpiresAt:   time.Now().Add(opts.expiration()).Truncate(time.Second),
+	}, nil
+}
+
+// Media 通过 Serve 输出 <id>.png 或 <id>.wav，路由参数名为 file，如 GET /captcha/:file；
+// 查询参数 lang 可以覆盖语音语言，download=1 以附件下载。opts 不合法时 panic
+func Media(opts CaptchaOptions) gin.HandlerFunc {
+	opts = opts.mustValidate()
+	return func(c *gin.Context) {
+		file := c.Param("file")
+		dot := strings.LastIndexByte(file, '.')
+		if dot <= 0 {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		id, ext := file[:dot], file[dot:]
+		if !exists(id) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		o := opts
+		if lang := c.Query("lang"); audioLangs[lang] {
+			o.Lang = lang
+		}
+		if c.Query("download") != "" {
+			o.Download = true
+		}
+		if err := Serve(c.Writer, c.Request, id, ext, o); err != nil {
+			c.Status(http.StatusNotFound)
+		}
+	}
+}
+
+// exists 已过期或已使用的验证码不再输出图片和语音；Get(id, false) 只读不消费
+func exists(id string) bool {
+	if store != nil {
+		return store.Get(id, false) != nil
+	}
+	// 默认存储没有只读查询接口，交给 captcha.WriteImage 处理不存在的 ID
+	return true
+}
+
+// RequireCaptcha 要求请求携带正确的验证码 ID 和答案（见包说明），校验失败时中止请求；
+// 与 VerifyHandler 一样按 limits 限流，超过返回 429，否则可以绕过 VerifyHandler 直接在这里猜答案
+func RequireCaptcha(limits VerifyLimits) gin.HandlerFunc {
+	return RequireChallenge(nil, limits)
+}
+
+// RequireChallenge 与 RequireCaptcha 相同，pow 不为 nil 时也接受工作量证明（见 pow.go）：
+// 请求头 X-Pow-Challenge / X-Pow-Solution 或请求体字段 pow_challenge / pow_solution，二者提交其一即可；
+// 工作量证明只按 IP 限流
+func RequireChallenge(pow *PoW, limits VerifyLimits) gin.HandlerFunc {
+	l := newLimiter(limits)
+	return func(c *gin.Context) {
+		sub := submission{
+			CaptchaID:    c.GetHeader(HeaderCaptchaID),
+			Answer:       c.GetHeader(HeaderCaptchaAnswer),
+			PowChallenge: c.GetHeader(HeaderPowChallenge),
+			PowSolution:  c.GetHeader(HeaderPowSolution),
+		}
+		if !sub.captcha() && !sub.pow() {
+			sub = submissionFromBody(c)
+		}
+		var err error
+		switch {
+		case pow != nil && sub.pow():
+			if l.limited(c, "") {
+				return
+			}
+			err = pow.verify(c.Request.Context(), sub.PowChallenge, sub.PowSolution)
+		case sub.captcha():
+			if l.limited(c, sub.CaptchaID) {
+				return
+			}
+			err = verify(sub.CaptchaID, sub.Answer)
+		default:
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "请输入验证码", "reason": "captcha_missing"})
+			return
+		}
+		if err != nil {
+			status, body := captchaError(err)
+			c.AbortWithStatusJSON(status, body)
+			return
+		}
+		c.Next()
+	}
+}
+
+// submission 客户端提交的验证码答案或工作量证明
+type submission struct {
+	CaptchaID    string `json:"captcha_id"`
+	Answer       string `json:"captcha_answer"`
+	PowChallenge string `json:"pow_challenge"`
+	PowSolution  string `json:"pow_solution"`
+}
+
+func (s submission) captcha() bool { return s.CaptchaID != "" && s.Answer != "" }
+func (s submission) pow() bool     { return s.PowChallenge != "" && s.PowSolution != "" }
+
+// submissionFromBody 从 JSON 或表单请求体中读取 submission；
+// JSON 请求体读取后放回，后面的处理函数仍可以用 ShouldBindJSON 绑定
+func submissionFromBody(c *gin.Context) submission {
+	if c.ContentType() != gin.MIMEJSON {
+		return submission{
+			CaptchaID:    c.PostForm("captcha_id"),
+			Answer:       c.PostForm("captcha_answer"),
+			PowChallenge: c.PostForm("pow_challenge"),
+			PowSolution:  c.PostForm("pow_solution"),
+		}
+	}
+	var sub submission
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return sub
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	_ = json.Unmarshal(body, &sub)
+	return sub
+}
+
+// verify 使用 UseStore 设置的存储校验，未设置时使用 dchest/captcha 默认存储（只能校验一次）；结果计入 Metrics
+func verify(id, answer string) error {
+	var err error
+	switch {
+	case store != nil:
+		err = store.Verify(id, answer)
+	case !captcha.VerifyString(id, answer):
+		err = ErrNotFound
+	}
+	Metrics.Add(metricName(err), 1)
+	return err
+}
+
+func captchaError(err error) (int, gin.H) {
+	switch {
+	case errors.Is(err, ErrMismatch):
+		return http.StatusForbidden, gin.H{"error": "验证码错误", "reason": "captcha_invalid"}
+	case errors.Is(err, ErrTooManyAttempts):
+		return http.StatusForbidden, gin.H{"error": "验证码错误次数过多，请刷新验证码", "reason": "captcha_too_many_attempts"}
+	case errors.Is(err, ErrPowInvalid):
+		return http.StatusForbidden, gin.H{"error": "工作量证明无效，请重新获取", "reason": "pow_invalid"}
+	case errors.Is(err, ErrNotFound):
+		return http.StatusForbidden, gin.H{"error": "验证码已过期，请刷新验证码", "reason": "captcha_expired"}
+	default:
+		return http.StatusInternalServerError, gin.H{"error": "校验验证码失败"}
+	}
+}
@@ -0,0 +1,148 @@
+package verifier
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"math/rand/v2"
+	"time"
+
+	"github.com/dchest/captcha"
+)
+
+// CaptchaOptions 验证码生成参数，零值字段使用默认值。dchest/captcha 只生成数字验证码，不支持自定义字符集
+type CaptchaOptions struct {
+	Length   int           // 位数，默认 captcha.DefaultLen（6），最多 16
+	Width    int           // 图片宽度，默认 captcha.StdWidth（240）
+	Height   int           // 图片高度，默认 captcha.StdHeight（80）
+	Noise    int           // 在库自带干扰之外额外添加的噪点和干扰线等级，0-5
+	Lang     string        // 语音语言：en / ja / ru / zh，默认 zh
+	TTL      time.Duration // 有效期，默认为存储的 Expiration；需要先调用 UseStore，否则使用 captcha.Expiration
+	Download bool          // 以附件形式下载，而不是在页面中显示
+}
+
+// 预设，按路由选用
+var (
+	// LoginCaptcha 登录：位数少、图片小，连续失败几次后才出现
+	LoginCaptcha = CaptchaOptions{Length: 4, Width: 107, Height: 36}
+	// RegisterCaptcha 注册：防批量注册，干扰更多
+	RegisterCaptcha = CaptchaOptions{Length: 6, Noise: 2, TTL: 5 * time.Minute}
+	// SMSCaptcha 发送短信：每条短信都有成本，干扰最多、有效期最短
+	SMSCaptcha = CaptchaOptions{Length: 6, Noise: 3, TTL: 2 * time.Minute}
+)
+
+// 取值范围
+const (
+	maxLength = 16
+	minSize   = 20
+	maxSize   = 1000
+	maxNoise  = 5
+)
+
+var audioLangs = map[string]bool{"en": true, "ja": true, "ru": true, "zh": true}
+
+// Validate 填充默认值并检查取值范围
+func (o CaptchaOptions) Validate() (CaptchaOptions, error) {
+	if o.Length == 0 {
+		o.Length = captcha.DefaultLen
+	}
+	if o.Width == 0 {
+		o.Width = captcha.StdWidth
+	}
+	if o.Height == 0 {
+		o.Height = captcha.StdHeight
+	}
+	if o.Lang == "" {
+		o.Lang = "zh"
+	}
+	switch {
+	case o.Length < 1 || o.Length > maxLength:
+		return o, fmt.Errorf("verifier: captcha length %d out of range 1-%d", o.Length, maxLength)
+	case o.Width < minSize || o.Width > maxSize || o.Height < minSize || o.Height > maxSize:
+		return o, fmt.Errorf("verifier: captcha size %dx%d out of range %d-%d", o.Width, o.Height, minSize, maxSize)
+	case o.Noise < 0 || o.Noise > maxNoise:
+		return o, fmt.Errorf("verifier: captcha noise %d out of range 0-%d", o.Noise, maxNoise)
+	case !audioLangs[o.Lang]:
+		return o, fmt.Errorf("verifier: unsupported captcha audio language %q", o.Lang)
+	case o.TTL < 0:
+		return o, fmt.Errorf("verifier: negative captcha ttl %s", o.TTL)
+	}
+	return o, nil
+}
+
+// mustValidate 用于创建处理函数时检查参数，参数写错属于编程错误，启动时直接 panic
+func (o CaptchaOptions) mustValidate() CaptchaOptions {
+	o, err := o.Validate()
+	if err != nil {
+		panic(err)
+	}
+	return o
+}
+
+// expiration 验证码实际的有效期
+func (o CaptchaOptions) expiration() time.Duration {
+	switch {
+	case o.TTL > 0 && store != nil:
+		return o.TTL
+	case store != nil:
+		return store.Expiration
+	default:
+		return captcha.Expiration
+	}
+}
+
+// newID 生成验证码并按 TTL 设置有效期
+func (o CaptchaOptions) newID() string {
+	id := captcha.NewLen(o.Length)
+	if o.TTL > 0 && store != nil {
+		store.expireIn(id, o.TTL)
+	}
+	return id
+}
+
+// writeImage 输出 PNG，Noise>0 时在库生成的图片上再画噪点和干扰线
+func (o CaptchaOptions) writeImage(buf *bytes.Buffer, id string) error {
+	if err := captcha.WriteImage(buf, id, o.Width, o.Height); err != nil {
+		return err
+	}
+	if o.Noise == 0 {
+		return nil
+	}
+	img, err := png.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return err
+	}
+	p, ok := img.(*image.Paletted)
+	if !ok || len(p.Palette) < 2 {
+		return nil
+	}
+	addNoise(p, o.Noise)
+	buf.Reset()
+	return png.Encode(buf, p)
+}
+
+// addNoise 按等级画随机噪点和干扰线，颜色取自图片调色板（下标 0 是背景色）
+func addNoise(p *image.Paletted, level int) {
+	b := p.Bounds()
+	color := func() uint8 { return uint8(1 + rand.IntN(len(p.Palette)-1)) }
+	for range level * b.Dx() * b.Dy() / 150 {
+		p.SetColorIndex(b.Min.X+rand.IntN(b.Dx()), b.Min.Y+rand.IntN(b.Dy()), color())
+	}
+	for range level {
+		x0, y0 := rand.IntN(b.Dx()), rand.IntN(b.Dy())
+		x1, y1 := rand.IntN(b.Dx()), rand.IntN(b.Dy())
+		c := color()
+		steps := max(abs(x1-x0), abs(y1-y0), 1)
+		for i := 0; i <= steps; i++ {
+			p.SetColorIndex(b.Min.X+x0+(x1-x0)*i/steps, b.Min.Y+y0+(y1-y0)*i/steps, c)
+		}
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
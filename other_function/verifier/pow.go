@@ -0,0 +1,259 @@
+package verifier
+
+/*
+工作量证明（proof-of-work，hashcash）：图片/语音验证码的替代方案，不需要用户识别任何内容，
+对视障用户和自动化的第一方客户端更友好，同时让批量请求付出计算成本。
+
+	POST /pow/challenge -> {"challenge","difficulty","expires_at","algorithm":"sha256"}
+	客户端找到 solution 使 sha256(challenge + ":" + solution) 的前 difficulty 个比特为 0，
+	提交 {"pow_challenge","pow_solution"}（或请求头 X-Pow-Challenge / X-Pow-Solution）
+
+challenge 带 HMAC 签名和过期时间，难度由服务端决定、客户端无法修改，签发时不需要保存任何状态；
+每个 challenge 只能使用一次，校验通过后才记录已使用的 nonce，保留到 challenge 过期。
+同一个 IP 短时间内申请的 challenge 越多，难度越高，超过限流直接返回 429。浏览器端求解见 pow_solver.js（GET /pow/solver.js）。
+*/
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/bits"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gin_learn/session_control/kv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/securecookie"
+)
+
+// 提交工作量证明的请求头
+const (
+	HeaderPowChallenge = "X-Pow-Challenge"
+	HeaderPowSolution  = "X-Pow-Solution"
+)
+
+// ErrPowInvalid challenge 签名错误或格式错误
+var ErrPowInvalid = errors.New("verifier: invalid proof-of-work challenge")
+
+// powPrefix 工作量证明在 kv 中的 key 前缀：pow:used:<nonce> 已使用的 challenge，pow:issued:<ip> 申请计数
+const powPrefix = "pow:"
+
+//go:embed pow_solver.js
+var powSolverJS []byte
+
+// PoW 工作量证明，字段在创建后不要修改
+type PoW struct {
+	Difficulty    int           // 基础难度（前导 0 比特数），默认 16，浏览器约 1 秒
+	MaxDifficulty int           // 难度上限，默认 22
+	Burst         int           // Window 内同一 IP 申请超过 Burst 个 challenge 后，每翻一倍难度加 1，默认 10
+	Window        time.Duration // 默认 1 分钟
+	TTL           time.Duration // challenge 有效期，默认 2 分钟
+
+	key atomic.Pointer[[]byte]
+	kv  kv.Store
+	mu  sync.Mutex
+}
+
+// NewPoW 创建工作量证明，key 用于签名 challenge（多副本部署时需要相同），用 Keyring.SigningKey(session.PurposePoW)
+// 派生的专用密钥，不要直接用 Session / Cookie 的签名密钥；
+// backend 保存已使用的 challenge 和申请计数，为 nil 时使用 UseStore 设置的存储或进程内存
+func NewPoW(key []byte, backend kv.Store) *PoW {
+	p := &PoW{Difficulty: 16, MaxDifficulty: 22, Burst: 10, Window: time.Minute, TTL: 2 * time.Minute, kv: backend}
+	p.SetKey(key)
+	return p
+}
+
+// SetKey 替换签名密钥（密钥环轮换后调用）；用旧密钥签名、还没提交的 challenge 随之失效，客户端重新申请即可
+func (p *PoW) SetKey(key []byte) {
+	p.key.Store(&key)
+}
+
+type powPayload struct {
+	Nonce      string `json:"n"`
+	Difficulty int    `json:"d"`
+	ExpiresAt  int64  `json:"e"`
+}
+
+// PowChallenge POST /pow/challenge 的响应
+type PowChallenge struct {
+	Challenge  string    `json:"challenge"`
+	Difficulty int       `json:"difficulty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Algorithm  string    `json:"algorithm"`
+}
+
+func (p *PoW) backend() kv.Store {
+	if p.kv != nil {
+		return p.kv
+	}
+	return limiterKV()
+}
+
+// Issue 为 ip 生成 challenge
+func (p *PoW) Issue(ctx context.Context, ip string) (PowChallenge, error) {
+	difficulty, err := p.difficulty(ctx, ip)
+	if err != nil {
+		return PowChallenge{}, err
+	}
+	expires := time.Now().Add(p.TTL).Truncate(time.Second)
+	nonce := hex.EncodeToString(securecookie.GenerateRandomKey(16))
+	payload, err := json.Marshal(powPayload{Nonce: nonce, Difficulty: difficulty, ExpiresAt: expires.Unix()})
+	if err != nil {
+		return PowChallenge{}, err
+	}
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	return PowChallenge{
+		Challenge:  body + "." + p.sign(body),
+		Difficulty: difficulty,
+		ExpiresAt:  expires,
+		Algorithm:  "sha256",
+	}, nil
+}
+
+// difficulty 记录一次申请并按 Window 内的申请次数计算难度
+func (p *PoW) difficulty(ctx context.Context, ip string) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	backend, now := p.backend(), time.Now()
+	key := powPrefix + "issued:" + ip
+	var w window
+	b, err := backend.Get(ctx, key)
+	switch {
+	case err == nil:
+		if err = json.Unmarshal(b, &w); err != nil {
+			return 0, err
+		}
+	case !errors.Is(err, kv.ErrNotFound):
+		return 0, err
+	}
+	if !now.Before(w.ResetAt) {
+		w = window{ResetAt: now.Add(p.Window)}
+	}
+	w.Count++
+	if b, err = json.Marshal(w); err != nil {
+		return 0, err
+	}
+	if err = backend.Set(ctx, key, b, w.ResetAt.Sub(now)); err != nil {
+		return 0, err
+	}
+	extra := 0
+	if p.Burst > 0 {
+		extra = bits.Len(uint(w.Count / p.Burst))
+	}
+	return min(p.Difficulty+extra, p.MaxDifficulty), nil
+}
+
+// Check 校验解答，通过后 challenge 即作废；返回 nil、ErrPowInvalid、ErrMismatch（解答不对）或 ErrNotFound（已过期或已使用）。
+// 签名、有效期和解答都通过后才用 SetNX 记录 nonce，多副本共享存储时同一个 challenge 也只能通过一次
+func (p *PoW) Check(ctx context.Context, challenge, solution string) error {
+	body, sig, ok := strings.Cut(challenge, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(p.sign(body))) {
+		return ErrPowInvalid
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return ErrPowInvalid
+	}
+	var pl powPayload
+	if err = json.Unmarshal(raw, &pl); err != nil {
+		return ErrPowInvalid
+	}
+	ttl := time.Until(time.Unix(pl.ExpiresAt, 0))
+	if ttl <= 0 {
+		return ErrNotFound
+	}
+	if solution == "" || len(solution) > 32 || leadingZeros(sha256.Sum256([]byte(challenge+":"+solution))) < pl.Difficulty {
+		return ErrMismatch
+	}
+	fresh, err := p.backend().SetNX(ctx, powPrefix+"used:"+pl.Nonce, []byte("1"), ttl)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Verify 与 CaptchaVerify 签名相同，code 为 "<challenge>:<solution>"，结果计入 Metrics（pow_ 前缀）
+func (p *PoW) Verify(c *gin.Context, code string) bool {
+	challenge, solution, ok := strings.Cut(code, ":")
+	if !ok {
+		return false
+	}
+	return p.verify(c.Request.Context(), challenge, solution) == nil
+}
+
+// verify 校验并把结果计入 Metrics
+func (p *PoW) verify(ctx context.Context, challenge, solution string) error {
+	err := p.Check(ctx, challenge, solution)
+	Metrics.Add("pow_"+powMetricName(err), 1)
+	return err
+}
+
+func powMetricName(err error) string {
+	if errors.Is(err, ErrPowInvalid) {
+		return "invalid"
+	}
+	return metricName(err)
+}
+
+func (p *PoW) sign(body string) string {
+	m := hmac.New(sha256.New, *p.key.Load())
+	m.Write([]byte("pow:" + body))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+func leadingZeros(sum [sha256.Size]byte) int {
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
+
+// ChallengeHandler 返回 POST /pow/challenge 的处理函数；每个 IP 在 limits.Window 内最多申请 limits.PerIP 个 challenge，
+// 超过返回 429（与校验的限流分开计数，PerCaptcha 不使用）
+func (p *PoW) ChallengeHandler(limits VerifyLimits) gin.HandlerFunc {
+	l := newLimiter(limits)
+	l.scope, l.kv = "pow_issue:", p.kv
+	return func(c *gin.Context) {
+		if l.limited(c, "") {
+			return
+		}
+		ch, err := p.Issue(c.Request.Context(), c.ClientIP())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成challenge失败"})
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, ch)
+	}
+}
+
+// SolverHandler 输出浏览器端求解脚本，如 GET /pow/solver.js
+func SolverHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "application/javascript; charset=utf-8", powSolverJS)
+}
+
+// VerifyOrCaptcha 与 CaptchaVerify 签名相同，用于同一个字段既可以填验证码也可以填工作量证明的场景：
+// "<challenge>:<solution>"（challenge 中带 "."）按工作量证明校验，其他按 CaptchaVerify 校验。
+// 只按格式选一种校验，工作量证明失败不会再去比对 Session 中的验证码、消耗它的答错次数
+func (p *PoW) VerifyOrCaptcha(c *gin.Context, code string) bool {
+	if challenge, _, ok := strings.Cut(code, ":"); ok && strings.Contains(challenge, ".") {
+		return p.Verify(c, code)
+	}
+	return CaptchaVerify(c, code)
+}
@@ -0,0 +1,48 @@
+// 工作量证明求解脚本，服务端见 pow.go。
+//
+//   const pow = await Pow.fetchAndSolve("/pow/challenge");
+//   fetch("/sms/send", {method: "POST", headers: {"X-Pow-Challenge": pow.challenge, "X-Pow-Solution": pow.solution}});
+//
+// 找到 solution 使 sha256(challenge + ":" + solution) 的前 difficulty 个比特为 0，
+// 使用 WebCrypto，不需要用户操作；onProgress(tries) 可以用来显示进度。
+(function (global) {
+  "use strict";
+
+  const encoder = new TextEncoder();
+
+  function leadingZeros(bytes) {
+    let n = 0;
+    for (const b of bytes) {
+      if (b !== 0) {
+        return n + Math.clz32(b) - 24;
+      }
+      n += 8;
+    }
+    return n;
+  }
+
+  async function solve(challenge, difficulty, onProgress) {
+    for (let i = 0; ; i++) {
+      const solution = i.toString();
+      const digest = await crypto.subtle.digest("SHA-256", encoder.encode(challenge + ":" + solution));
+      if (leadingZeros(new Uint8Array(digest)) >= difficulty) {
+        return solution;
+      }
+      if (onProgress && i % 4096 === 0) {
+        onProgress(i);
+      }
+    }
+  }
+
+  async function fetchAndSolve(url, onProgress) {
+    const resp = await fetch(url, {method: "POST", credentials: "same-origin"});
+    if (!resp.ok) {
+      throw new Error("pow: challenge request failed: " + resp.status);
+    }
+    const c = await resp.json();
+    const solution = await solve(c.challenge, c.difficulty, onProgress);
+    return {challenge: c.challenge, solution: solution};
+  }
+
+  global.Pow = {solve: solve, fetchAndSolve: fetchAndSolve};
+})(typeof window !== "undefined" ? window : self);
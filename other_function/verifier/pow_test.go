@@ -0,0 +1,150 @@
+package verifier
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gin_learn/session_control/kv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestPoW(t *testing.T, key string) (*PoW, *kv.Memory) {
+	t.Helper()
+	backend := kv.NewMemory(time.Hour)
+	t.Cleanup(func() { _ = backend.Close() })
+	p := NewPoW([]byte(key), backend)
+	p.Difficulty, p.MaxDifficulty = 8, 8
+	return p, backend
+}
+
+// solve 找到使前导 0 比特数满足 ok 的解答
+func solve(t *testing.T, challenge string, ok func(zeros int) bool) string {
+	t.Helper()
+	for i := range 1 << 20 {
+		s := strconv.Itoa(i)
+		if ok(leadingZeros(sha256.Sum256([]byte(challenge + ":" + s)))) {
+			return s
+		}
+	}
+	t.Fatal("no solution found")
+	return ""
+}
+
+func TestPoWCheck(t *testing.T) {
+	ctx := context.Background()
+	p, _ := newTestPoW(t, "key-a")
+	other, _ := newTestPoW(t, "key-b")
+	expired, _ := newTestPoW(t, "key-a")
+	expired.TTL = -time.Second
+
+	issue := func(p *PoW) string {
+		ch, err := p.Issue(ctx, "10.0.0.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ch.Challenge
+	}
+	enough := func(zeros int) bool { return zeros >= 8 }
+
+	valid := issue(p)
+	forged := issue(other)
+	old := issue(expired)
+	weak := issue(p)
+	for _, tt := range []struct {
+		name      string
+		challenge string
+		solution  string
+		want      error
+	}{
+		{"bad signature", forged, solve(t, forged, enough), ErrPowInvalid},
+		{"tampered body", "x" + valid, solve(t, valid, enough), ErrPowInvalid},
+		{"expired", old, solve(t, old, enough), ErrNotFound},
+		{"insufficient zero bits", weak, solve(t, weak, func(zeros int) bool { return zeros < 8 }), ErrMismatch},
+		{"empty solution", valid, "", ErrMismatch},
+		{"valid", valid, solve(t, valid, enough), nil},
+		// 同一个 challenge 再次提交（即使换一个解答）
+		{"reused nonce", valid, solve(t, valid, enough), ErrNotFound},
+	} {
+		if err := p.Check(ctx, tt.challenge, tt.solution); !errors.Is(err, tt.want) {
+			t.Fatalf("%s: Check = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	// 解答不对时不记录 nonce，修正后仍可以提交
+	if err := p.Check(ctx, weak, solve(t, weak, enough)); err != nil {
+		t.Fatalf("Check after a wrong solution = %v", err)
+	}
+}
+
+// TestPoWIssueStateless 签发 challenge 不保存 nonce，校验通过后才记录
+func TestPoWIssueStateless(t *testing.T) {
+	ctx := context.Background()
+	p, backend := newTestPoW(t, "key-a")
+	ch, err := p.Issue(ctx, "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _, _ := strings.Cut(ch.Challenge, ".")
+	raw, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pl powPayload
+	if err = json.Unmarshal(raw, &pl); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{powPrefix + pl.Nonce, powPrefix + "used:" + pl.Nonce} {
+		if _, err = backend.Get(ctx, key); !errors.Is(err, kv.ErrNotFound) {
+			t.Fatalf("Get(%s) after Issue = %v, want ErrNotFound", key, err)
+		}
+	}
+	if err = p.Check(ctx, ch.Challenge, solve(t, ch.Challenge, func(zeros int) bool { return zeros >= 8 })); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = backend.Get(ctx, powPrefix+"used:"+pl.Nonce); err != nil {
+		t.Fatalf("used marker after Check = %v", err)
+	}
+}
+
+func TestPoWChallengeHandlerLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	p, _ := newTestPoW(t, "key-a")
+	r := gin.New()
+	r.POST("/pow/challenge", p.ChallengeHandler(VerifyLimits{PerIP: 2, Window: time.Minute}))
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/pow/challenge", nil))
+		if w.Code != want {
+			t.Fatalf("request %d = %d, want %d", i+1, w.Code, want)
+		}
+	}
+}
+
+// TestVerifyOrCaptcha 工作量证明格式的答案只按工作量证明校验：这里没有注册 Session 中间件，
+// 如果失败后继续调用 CaptchaVerify 会 panic
+func TestVerifyOrCaptcha(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	p, _ := newTestPoW(t, "key-a")
+	ch, err := p.Issue(context.Background(), "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+	bad := solve(t, ch.Challenge, func(zeros int) bool { return zeros < 8 })
+	if p.VerifyOrCaptcha(c, ch.Challenge+":"+bad) {
+		t.Fatal("wrong solution accepted")
+	}
+	if !p.VerifyOrCaptcha(c, ch.Challenge+":"+solve(t, ch.Challenge, func(zeros int) bool { return zeros >= 8 })) {
+		t.Fatal("valid solution rejected")
+	}
+}
@@ -0,0 +1,196 @@
+package verifier
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"gin_learn/session_control/kv"
+	"gin_learn/session_control/session"
+
+	"github.com/dchest/captcha"
+	"go.uber.org/zap"
+)
+
+// 默认值
+const (
+	DefaultExpiration  = captcha.Expiration // 10 分钟
+	DefaultMaxAttempts = 3
+)
+
+var (
+	// ErrNotFound 验证码不存在、已过期或已被使用
+	ErrNotFound = errors.New("verifier: captcha not found")
+	// ErrMismatch 答案错误，还可以重试
+	ErrMismatch = errors.New("verifier: captcha mismatch")
+	// ErrTooManyAttempts 答错次数达到上限，验证码已作废
+	ErrTooManyAttempts = errors.New("verifier: too many captcha attempts")
+)
+
+// captchaPrefix 验证码在 kv 中的 key 前缀
+const captchaPrefix = "captcha:"
+
+type entry struct {
+	Digits    []byte `json:"d"`
+	ExpiresAt int64  `json:"e"` // Unix 纳秒，答错后放回时用来计算剩余有效期
+	Attempts  int    `json:"a"`
+}
+
+// Store 实现 captcha.Store，把验证码答案保存在 kv 存储中（memory/file/bolt/redis），
+// 多副本共享同一个 redis 时在任何一个副本上都能校验；过期由存储负责清理。
+//
+// 校验时先用 kv.Take 原子地取出并删除答案，答对即消费，同一个 ID 无法重放；
+// 答错且未达到次数上限时放回，保留原来的过期时间
+type Store struct {
+	Expiration  time.Duration
+	MaxAttempts int // 每个验证码最多可以答错的次数，<=0 表示只能校验一次
+
+	kv kv.Store
+}
+
+// NewStore 创建验证码存储
+func NewStore(backend kv.Store, expiration time.Duration, maxAttempts int) *Store {
+	return &Store{Expiration: expiration, MaxAttempts: maxAttempts, kv: backend}
+}
+
+// OpenStore 按 session 配置（SESSION_BACKEND 等环境变量）打开存储，与 Session 共用同一个后端；
+// 有效期和答错次数可以用 CAPTCHA_EXPIRATION（如 "5m"）和 CAPTCHA_MAX_ATTEMPTS 覆盖
+func OpenStore() (*Store, error) {
+	cfg, err := session.LoadConfig("")
+	if err != nil {
+		return nil, err
+	}
+	expiration, maxAttempts := DefaultExpiration, DefaultMaxAttempts
+	if v := os.Getenv("CAPTCHA_EXPIRATION"); v != "" {
+		if expiration, err = time.ParseDuration(v); err != nil || expiration <= 0 {
+			return nil, fmt.Errorf("verifier: invalid CAPTCHA_EXPIRATION %q", v)
+		}
+	}
+	if v := os.Getenv("CAPTCHA_MAX_ATTEMPTS"); v != "" {
+		if maxAttempts, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("verifier: invalid CAPTCHA_MAX_ATTEMPTS %q", v)
+		}
+	}
+	backend, err := kv.Open(cfg.Store)
+	if err != nil {
+		return nil, err
+	}
+	return NewStore(backend, expiration, maxAttempts), nil
+}
+
+// Close 关闭底层存储
+func (s *Store) Close() error {
+	return s.kv.Close()
+}
+
+// Set 实现 captcha.Store，生成或刷新验证码时调用
+func (s *Store) Set(id string, digits []byte) {
+	e := entry{Digits: digits, ExpiresAt: time.Now().Add(s.Expiration).UnixNano()}
+	if err := s.put(id, e); err != nil {
+		zap.L().Warn("save captcha failed", zap.String("id", id), zap.Error(err))
+	}
+}
+
+// Get 实现 captcha.Store，clear 为 true 时原子地取出并删除（captcha.Verify 使用）
+func (s *Store) Get(id string, clear bool) []byte {
+	ctx := context.Background()
+	var (
+		b   []byte
+		err error
+	)
+	if clear {
+		b, err = s.kv.Take(ctx, captchaPrefix+id)
+	} else {
+		b, err = s.kv.Get(ctx, captchaPrefix+id)
+	}
+	if err != nil {
+		return nil
+	}
+	var e entry
+	if json.Unmarshal(b, &e) != nil {
+		return nil
+	}
+	return e.Digits
+}
+
+// expireIn 把验证码的有效期改为 ttl（CaptchaOptions.TTL）
+func (s *Store) expireIn(id string, ttl time.Duration) {
+	digits := s.Get(id, false)
+	if digits == nil {
+		return
+	}
+	if err := s.put(id, entry{Digits: digits, ExpiresAt: time.Now().Add(ttl).UnixNano()}); err != nil {
+		zap.L().Warn("save captcha failed", zap.String("id", id), zap.Error(err))
+	}
+}
+
+// Delete 作废验证码，如用户刷新图片后旧的 ID
+func (s *Store) Delete(id string) {
+	_ = s.kv.Delete(context.Background(), captchaPrefix+id)
+}
+
+// Verify 校验答案（如 "1234"），答对后验证码即被消费；
+// 返回 nil、ErrMismatch（可以重试）、ErrTooManyAttempts 或 ErrNotFound
+func (s *Store) Verify(id, answer string) error {
+	ctx := context.Background()
+	b, err := s.kv.Take(ctx, captchaPrefix+id)
+	if err != nil {
+		return ErrNotFound
+	}
+	var e entry
+	if json.Unmarshal(b, &e) != nil {
+		return ErrNotFound
+	}
+	if subtle.ConstantTimeCompare(e.Digits, parseDigits(answer)) == 1 {
+		return nil
+	}
+	e.Attempts++
+	if e.Attempts >= s.MaxAttempts {
+		return ErrTooManyAttempts
+	}
+	if time.Now().UnixNano() >= e.ExpiresAt {
+		return ErrNotFound
+	}
+	if err = s.put(id, e); err != nil {
+		return err
+	}
+	return ErrMismatch
+}
+
+func (s *Store) put(id string, e entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.kv.Set(context.Background(), captchaPrefix+id, b, time.Until(time.Unix(0, e.ExpiresAt)))
+}
+
+// parseDigits 与 captcha.VerifyString 相同：数字字符转成 0-9，忽略空格和逗号，其他字符使结果不匹配
+func parseDigits(s string) []byte {
+	ns := make([]byte, 0, len(s))
+	for i := range s {
+		d := s[i]
+		switch {
+		case '0' <= d && d <= '9':
+			ns = append(ns, d-'0')
+		case d == ' ' || d == ',':
+		default:
+			return nil
+		}
+	}
+	return ns
+}
+
+// store UseStore 设置的存储，为 nil 时使用 dchest/captcha 默认的进程内存存储
+var store *Store
+
+// UseStore 通过 captcha.SetCustomStore 替换 dchest/captcha 的全局存储，Captcha / CaptchaVerify 随之使用 s
+func UseStore(s *Store) {
+	store = s
+	captcha.SetCustomStore(s)
+}
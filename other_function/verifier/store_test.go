@@ -0,0 +1,90 @@
+package verifier
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gin_learn/session_control/kv"
+)
+
+func newTestStore(t *testing.T, maxAttempts int) *Store {
+	t.Helper()
+	backend := kv.NewMemory(time.Hour)
+	t.Cleanup(func() { _ = backend.Close() })
+	return NewStore(backend, time.Minute, maxAttempts)
+}
+
+func TestStoreVerify(t *testing.T) {
+	digits := []byte{1, 2, 3, 4}
+	for _, tt := range []struct {
+		name        string
+		maxAttempts int
+		answers     []string
+		want        []error
+	}{
+		// 答对即消费，同一个 ID 不能再用
+		{"consumed", 3, []string{"1234", "1234"}, []error{nil, ErrNotFound}},
+		// 答错后放回，还能用正确答案通过
+		{"mismatch then correct", 3, []string{"0000", "12 34"}, []error{ErrMismatch, nil}},
+		{"max attempts", 3, []string{"0000", "0000", "0000", "1234"}, []error{ErrMismatch, ErrMismatch, ErrTooManyAttempts, ErrNotFound}},
+		{"single attempt", 0, []string{"0000", "1234"}, []error{ErrTooManyAttempts, ErrNotFound}},
+		{"invalid characters", 3, []string{"12a4"}, []error{ErrMismatch}},
+	} {
+		s := newTestStore(t, tt.maxAttempts)
+		s.Set("id", digits)
+		for i, answer := range tt.answers {
+			if err := s.Verify("id", answer); !errors.Is(err, tt.want[i]) {
+				t.Fatalf("%s: Verify #%d(%q) = %v, want %v", tt.name, i+1, answer, err, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestStoreVerifyExpired(t *testing.T) {
+	s := newTestStore(t, 3)
+	s.Set("id", []byte{1, 2, 3, 4})
+	s.expireIn("id", 50*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	if err := s.Verify("id", "1234"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Verify after expiry = %v, want ErrNotFound", err)
+	}
+}
+
+// TestStoreGetClear captcha.Verify 通过 Get(id, true) 校验，同样只能取出一次
+func TestStoreGetClear(t *testing.T) {
+	s := newTestStore(t, 3)
+	s.Set("id", []byte{1, 2, 3, 4})
+	if got := s.Get("id", false); len(got) != 4 {
+		t.Fatalf("Get(id, false) = %v", got)
+	}
+	if got := s.Get("id", true); len(got) != 4 {
+		t.Fatalf("Get(id, true) = %v", got)
+	}
+	if got := s.Get("id", true); got != nil {
+		t.Fatalf("second Get(id, true) = %v, want nil", got)
+	}
+}
+
+// TestStoreVerifyConcurrent 同一个验证码并发提交正确答案，只有一个能通过
+func TestStoreVerifyConcurrent(t *testing.T) {
+	s := newTestStore(t, 3)
+	s.Set("id", []byte{1, 2, 3, 4})
+	var wg sync.WaitGroup
+	var won atomic.Int32
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if s.Verify("id", "1234") == nil {
+				won.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := won.Load(); n != 1 {
+		t.Fatalf("%d concurrent Verify calls succeeded, want 1", n)
+	}
+}
@@ -0,0 +1,178 @@
+package verifier
+
+/*
+图形验证码：把验证码ID保存在 Session（gin-contrib/sessions）中，校验时取出比对，一次有效。
+需要先用 Session 中间件启用 Session，见 other_function/gin_verifier_code.go。
+*/
+
+import (
+	"bytes"
+	"errors"
+	"gin_learn/session_control/session"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dchest/captcha"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	gsessions "github.com/gorilla/sessions"
+)
+
+// 中间件，处理session
+func Session(name string, store sessions.Store) gin.HandlerFunc {
+	return sessions.Sessions(name, store)
+}
+
+// SessionConfig 创建 Cookie 型 Session 存储；密钥与 session_control 共用同一个密钥环
+// （SESSION_KEYS 环境变量或 SESSION_KEYRING 指向的文件，用 go run ./session_control/keyctl init 生成），
+// 密钥缺失或长度不合法时返回错误
+func SessionConfig() (sessions.Store, error) {
+	sessionMaxAge := 3600
+	cfg, err := session.LoadConfig("")
+	if err != nil {
+		return nil, err
+	}
+	keyring, err := session.LoadKeyring(cfg.Keyring)
+	if err != nil {
+		return nil, err
+	}
+	return SessionStore(sessionMaxAge, keyring.KeyPairs(session.PurposeCaptcha, time.Now())...), nil
+}
+
+// SessionStore 用给定的密钥创建 Cookie 型 Session 存储，maxAge 单位为秒
+func SessionStore(maxAge int, keyPairs ...[]byte) *CookieStore {
+	store := &CookieStore{}
+	store.Options(sessions.Options{
+		MaxAge:   maxAge, //seconds
+		Path:     "/",
+		HttpOnly: true,
+	})
+	store.SetKeyPairs(keyPairs...)
+	return store
+}
+
+// CookieStore Cookie 型 Session 存储，密钥可以在运行中替换（见 SetKeyPairs）
+type CookieStore struct {
+	mu      sync.RWMutex
+	store   sessions.Store
+	options sessions.Options
+}
+
+// SetKeyPairs 替换签名/加密密钥（如密钥环轮换后），第一组用于签名新的 Cookie，其余只用于解码
+func (s *CookieStore) SetKeyPairs(keyPairs ...[]byte) {
+	store := cookie.NewStore(keyPairs...)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	store.Options(s.options)
+	s.store = store
+}
+
+func (s *CookieStore) current() sessions.Store {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.store
+}
+
+// Get 见 gorilla/sessions.Store
+func (s *CookieStore) Get(r *http.Request, name string) (*gsessions.Session, error) {
+	return s.current().Get(r, name)
+}
+
+// New 见 gorilla/sessions.Store
+func (s *CookieStore) New(r *http.Request, name string) (*gsessions.Session, error) {
+	return s.current().New(r, name)
+}
+
+// Save 见 gorilla/sessions.Store
+func (s *CookieStore) Save(r *http.Request, w http.ResponseWriter, session *gsessions.Session) error {
+	return s.current().Save(r, w, session)
+}
+
+// Options 设置之后创建的 Session 的 Cookie 属性，替换密钥后仍然生效
+func (s *CookieStore) Options(options sessions.Options) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.options = options
+	if s.store != nil {
+		s.store.Options(options)
+	}
+}
+
+// Captcha 按 opts 生成验证码并把ID写入 Session，输出 PNG 图片
+func Captcha(c *gin.Context, opts CaptchaOptions) {
+	opts, err := opts.Validate()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成验证码失败"})
+		return
+	}
+	captchaId := opts.newID()
+	session := sessions.Default(c)
+	// 刷新验证码时作废旧的 ID，不让它在存储中等到过期
+	if old, ok := session.Get("captcha").(string); ok && store != nil {
+		store.Delete(old)
+	}
+	session.Set("captcha", captchaId)
+	_ = session.Save()
+	_ = Serve(c.Writer, c.Request, captchaId, ".png", opts)
+}
+
+// CaptchaHandler 返回按 opts 生成验证码的处理函数，opts 不合法时 panic
+func CaptchaHandler(opts CaptchaOptions) gin.HandlerFunc {
+	opts = opts.mustValidate()
+	return func(c *gin.Context) {
+		Captcha(c, opts)
+	}
+}
+
+// CaptchaVerify 校验验证码。使用 UseStore 设置的存储时，答错且未达到次数上限可以重试，其他情况 Session 中的验证码都会被删除；
+// 否则无论成功与否都会删除
+func CaptchaVerify(c *gin.Context, code string) bool {
+	session := sessions.Default(c)
+	captchaId, ok := session.Get("captcha").(string)
+	if !ok {
+		return false
+	}
+	if store == nil {
+		session.Delete("captcha")
+		_ = session.Save()
+		return captcha.VerifyString(captchaId, code)
+	}
+	err := store.Verify(captchaId, code)
+	if !errors.Is(err, ErrMismatch) {
+		session.Delete("captcha")
+		_ = session.Save()
+	}
+	return err == nil
+}
+
+// Serve 输出验证码图片（.png，尺寸和干扰按 opts）或语音（.wav，语言按 opts.Lang）；opts 应已经过 Validate
+func Serve(w http.ResponseWriter, r *http.Request, id, ext string, opts CaptchaOptions) error {
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Set("Pragma", "no-cache")
+	w.Header().Set("Expires", "0")
+
+	var content bytes.Buffer
+	switch ext {
+	case ".png":
+		w.Header().Set("Content-Type", "image/png")
+		if err := opts.writeImage(&content, id); err != nil {
+			return err
+		}
+	case ".wav":
+		w.Header().Set("Content-Type", "audio/x-wav")
+		if err := captcha.WriteAudio(&content, id, opts.Lang); err != nil {
+			return err
+		}
+	default:
+		return captcha.ErrNotFound
+	}
+
+	if opts.Download {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="captcha`+ext+`"`)
+	}
+	http.ServeContent(w, r, id+ext, time.Time{}, bytes.NewReader(content.Bytes()))
+	return nil
+}
@@ -0,0 +1,49 @@
+package verifier
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+)
+
+// TestCookieStoreSetKeyPairs 轮换后旧密钥签发的 Cookie 在宽限期内仍可解码，旧密钥移除后不再有效
+func TestCookieStoreSetKeyPairs(t *testing.T) {
+	oldKey, newKey := securecookie.GenerateRandomKey(32), securecookie.GenerateRandomKey(32)
+	s := SessionStore(600, oldKey, nil)
+
+	w := httptest.NewRecorder()
+	sess, _ := s.New(httptest.NewRequest(http.MethodGet, "/", nil), "captcha_session")
+	sess.Values["captcha"] = "id"
+	if err := s.Save(httptest.NewRequest(http.MethodGet, "/", nil), w, sess); err != nil {
+		t.Fatal(err)
+	}
+	load := func() (string, error) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, c := range w.Result().Cookies() {
+			r.AddCookie(c)
+		}
+		sess, err := s.New(r, "captcha_session")
+		id, _ := sess.Values["captcha"].(string)
+		return id, err
+	}
+
+	s.SetKeyPairs(newKey, nil, oldKey, nil)
+	if id, err := load(); err != nil || id != "id" {
+		t.Fatalf("after rotation = %q, %v, want the old cookie to decode", id, err)
+	}
+	s.SetKeyPairs(newKey, nil)
+	if id, _ := load(); id != "" {
+		t.Fatalf("after retiring the old key = %q, want empty", id)
+	}
+	// Options 在替换密钥后仍然生效
+	w = httptest.NewRecorder()
+	sess, _ = s.New(httptest.NewRequest(http.MethodGet, "/", nil), "captcha_session")
+	if err := s.Save(httptest.NewRequest(http.MethodGet, "/", nil), w, sess); err != nil {
+		t.Fatal(err)
+	}
+	if c := w.Result().Cookies(); len(c) != 1 || !c[0].HttpOnly || c[0].MaxAge != 600 {
+		t.Fatalf("cookie = %+v, want HttpOnly with MaxAge 600", c)
+	}
+}
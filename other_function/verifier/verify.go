@@ -0,0 +1,210 @@
+package verifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"gin_learn/session_control/kv"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics 验证码校验结果计数，key 为 success / invalid / expired / too_many_attempts / rate_limited / missing，
+// 工作量证明的结果加 pow_ 前缀；通过 expvar 以 "captcha_verify" 发布，挂上 gin.WrapH(expvar.Handler()) 即可在 /debug/vars 查看
+var Metrics = expvar.NewMap("captcha_verify")
+
+func metricName(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, ErrMismatch):
+		return "invalid"
+	case errors.Is(err, ErrTooManyAttempts):
+		return "too_many_attempts"
+	default:
+		return "expired"
+	}
+}
+
+// VerifyLimits 校验验证码的限流（VerifyHandler、RequireCaptcha、RequireChallenge）：
+// Window 内每个 IP、每个验证码 ID 最多校验的次数（<=0 不限制），超过返回 429。
+// 按验证码 ID 而不是按 Session 计数：丢掉 Cookie 就能换一个新 Session，计数随之清零
+type VerifyLimits struct {
+	PerIP      int
+	PerCaptcha int
+	Window     time.Duration
+}
+
+// DefaultVerifyLimits 默认限流：每分钟每个 IP 30 次、每个验证码 5 次
+var DefaultVerifyLimits = VerifyLimits{PerIP: 30, PerCaptcha: 5, Window: time.Minute}
+
+// rateLimitPrefix 限流计数在 kv 中的 key 前缀
+const rateLimitPrefix = "captcha_rl:"
+
+type window struct {
+	Count   int       `json:"count"`
+	ResetAt time.Time `json:"reset_at"`
+}
+
+type limiter struct {
+	VerifyLimits
+	scope string   // 计数 key 的前缀，不同用途的限流分开计数
+	kv    kv.Store // 保存计数的存储，为 nil 时使用 limiterKV()
+	mu    sync.Mutex
+}
+
+func newLimiter(limits VerifyLimits) *limiter {
+	if limits.Window <= 0 {
+		limits.Window = DefaultVerifyLimits.Window
+	}
+	return &limiter{VerifyLimits: limits}
+}
+
+// fallbackKV 未调用 UseStore 时限流计数保存在进程内存中
+var (
+	fallbackOnce sync.Once
+	fallbackKV   kv.Store
+)
+
+func limiterKV() kv.Store {
+	if store != nil {
+		return store.kv
+	}
+	fallbackOnce.Do(func() { fallbackKV = kv.NewMemory(kv.DefaultJanitorInterval) })
+	return fallbackKV
+}
+
+// allow 计数加一，超过 limit 时返回需要等待的时长
+func (l *limiter) allow(ctx context.Context, key string, limit int) (time.Duration, error) {
+	if limit <= 0 {
+		return 0, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	backend, now := l.kv, time.Now()
+	if backend == nil {
+		backend = limiterKV()
+	}
+	var w window
+	b, err := backend.Get(ctx, rateLimitPrefix+key)
+	switch {
+	case err == nil:
+		if err = json.Unmarshal(b, &w); err != nil {
+			return 0, err
+		}
+	case !errors.Is(err, kv.ErrNotFound):
+		return 0, err
+	}
+	if !now.Before(w.ResetAt) {
+		w = window{ResetAt: now.Add(l.Window)}
+	}
+	if w.Count >= limit {
+		return w.ResetAt.Sub(now), nil
+	}
+	w.Count++
+	if b, err = json.Marshal(w); err != nil {
+		return 0, err
+	}
+	return 0, backend.Set(ctx, rateLimitPrefix+key, b, w.ResetAt.Sub(now))
+}
+
+// limited 按 IP 和验证码 ID（captchaID 为空时只按 IP）计数，超过限制时中止请求并写 429 响应
+func (l *limiter) limited(c *gin.Context, captchaID string) bool {
+	check := func(key string, limit int) bool {
+		retry, err := l.allow(c.Request.Context(), key, limit)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "校验验证码失败"})
+			return true
+		}
+		if retry <= 0 {
+			return false
+		}
+		Metrics.Add("rate_limited", 1)
+		secs := max(int(retry.Round(time.Second).Seconds()), 1)
+		c.Header("Retry-After", strconv.Itoa(secs))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "验证次数过多，请稍后再试", "reason": "rate_limited", "retry_after": secs})
+		return true
+	}
+	if check(l.scope+"ip:"+c.ClientIP(), l.PerIP) {
+		return true
+	}
+	return captchaID != "" && check(l.scope+"captcha:"+captchaID, l.PerCaptcha)
+}
+
+// VerifyHandler POST 校验验证码，答案在请求体中，不会出现在 URL 和访问日志里：
+//
+//	{"captcha_id":"...","answer":"1234"}  无状态模式（见 Create）
+//	{"answer":"1234"}                      Session 模式（见 Captcha），验证码 ID 从 Session 中取
+//
+// 通过返回 200 {"success":true}；失败返回 403 和 reason，同时作废原验证码并在 captcha 字段中返回新的验证码
+// （Session 模式下新 ID 写回 Session）；缺少参数返回 400，超过 limits（按 IP 和验证码 ID）返回 429。opts 为新验证码的参数，不合法时 panic
+func VerifyHandler(opts CaptchaOptions, limits VerifyLimits) gin.HandlerFunc {
+	opts = opts.mustValidate()
+	l := newLimiter(limits)
+	return func(c *gin.Context) {
+		var req struct {
+			CaptchaID string `json:"captcha_id"`
+			Answer    string `json:"answer" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			Metrics.Add("missing", 1)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请输入验证码", "reason": "captcha_missing"})
+			return
+		}
+		session := defaultSession(c)
+		id := req.CaptchaID
+		if id == "" && session != nil {
+			id, _ = session.Get("captcha").(string)
+		}
+		if id == "" {
+			Metrics.Add("missing", 1)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "验证码不存在，请刷新验证码", "reason": "captcha_missing"})
+			return
+		}
+		if l.limited(c, id) {
+			return
+		}
+
+		err := verify(id, req.Answer)
+		if err == nil {
+			if session != nil && req.CaptchaID == "" {
+				session.Delete("captcha")
+				_ = session.Save()
+			}
+			c.JSON(http.StatusOK, gin.H{"success": true})
+			return
+		}
+		status, body := captchaError(err)
+		if status == http.StatusInternalServerError {
+			c.JSON(status, body)
+			return
+		}
+		// 答错后换一个新的验证码，原验证码不能继续猜
+		if store != nil {
+			store.Delete(id)
+		}
+		if next, nerr := newCaptcha(c, opts); nerr == nil {
+			if session != nil && req.CaptchaID == "" {
+				session.Set("captcha", next.CaptchaID)
+				_ = session.Save()
+			}
+			body["captcha"] = next
+		}
+		c.JSON(status, body)
+	}
+}
+
+// defaultSession 注册了 Session 中间件时返回当前 Session，否则返回 nil
+func defaultSession(c *gin.Context) sessions.Session {
+	if _, ok := c.Get(sessions.DefaultKey); !ok {
+		return nil
+	}
+	return sessions.Default(c)
+}
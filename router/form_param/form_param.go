@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"net/http"
 
+	"gin_learn/session_control/csrf"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -18,6 +20,20 @@ func main() {
 
 	r := gin.Default()
 
+	// CSRF 防护（双重提交 Cookie）：打开表单页时下发 csrf_token Cookie，表单隐藏字段 _csrf 带回同一个值，
+	// 其他站点的页面拿不到这个值，伪造的提交会被 403 拒绝；签名密钥见 csrf.DefaultKey（先 go run ./session_control/keyctl init）
+	csrfKey, err := csrf.DefaultKey()
+	if err != nil {
+		panic("加载CSRF密钥失败: " + err.Error())
+	}
+	r.Use(csrf.Middleware(csrf.Options{Key: csrfKey}))
+	r.LoadHTMLFiles("./router/form_param/form_submit.html")
+
+	// 浏览器打开 http://localhost:8080/ 填写表单
+	r.GET("/", func(c *gin.Context) {
+		c.HTML(http.StatusOK, "form_submit.html", gin.H{"csrfField": csrf.TemplateField(c)})
+	})
+
 	// 访问路径示例: POST http:/localhost:8080/form
 	// 表单参数: name=John
 	r.POST("/form", func(c *gin.Context) {
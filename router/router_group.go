@@ -1,50 +1,191 @@
 // package main
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
+	"time"
+
+	"gin_learn/session_control/auth"
+	"gin_learn/session_control/cookie"
+	"gin_learn/session_control/csrf"
+	"gin_learn/session_control/kv"
+	"gin_learn/session_control/rbac"
+	"gin_learn/session_control/session"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/securecookie"
+	"go.uber.org/zap"
+)
+
+var (
+	// cookies 登录后用签名 Cookie 保存用户名
+	cookies *cookie.Codec
+	// 用户名密码校验和防暴力破解：角色按用户名授予，只凭用户名登录等于谁都能当 admin
+	authService *auth.Service
+	loginGuard  *auth.Guard
 )
 
 func main() {
+	cfg, err := session.LoadConfig("")
+	if err != nil {
+		panic(err)
+	}
+	keyring, err := session.LoadKeyring(cfg.Keyring)
+	if err != nil {
+		panic("加载Session密钥失败: " + err.Error())
+	}
+	if cookies, err = cookie.New(keyring.KeyPairs(session.PurposeCookie, time.Now())...); err != nil {
+		panic(err)
+	}
+
+	// 权限控制（RBAC）：用户 -> 角色 -> 权限，见 router/rbac_policy.json（可用 RBAC_POLICY 环境变量指定）
+	policy, err := rbac.LoadPolicy("")
+	if err != nil {
+		panic("加载权限策略失败: " + err.Error())
+	}
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+	enforcer := rbac.NewEnforcer(policy)
+	enforcer.Logger = logger // 拒绝访问的审计日志
+
+	// 用户存储：默认 bbolt 文件 ./session_control/data/users-router_group.db（见 auth.Open）
+	users, err := auth.Open("router_group")
+	if err != nil {
+		panic("打开用户存储失败: " + err.Error())
+	}
+	defer users.Close()
+	authService = auth.NewService(users)
+	if err = seedUsers(context.Background(), policy); err != nil {
+		panic("创建策略中的用户失败: " + err.Error())
+	}
+	// 这个示例没有验证码，只用失败计数和临时锁定
+	guardCfg := auth.DefaultGuardConfig
+	guardCfg.CaptchaAfter = 0
+	loginGuard = auth.NewGuard(kv.NewMemory(kv.DefaultJanitorInterval), guardCfg)
+	loginGuard.Logger = logger
+
 	r := gin.Default()
+	// 不信任 X-Forwarded-For，按 IP 的失败计数取连接地址
+	_ = r.SetTrustedProxies(nil)
+	// 识别当前用户（不强制登录），是否允许访问由各路由组要求的权限决定
+	r.Use(identify)
 
 	// 路由组是为了管理一些相同的URL
 	// 访问路径示例: GET http:/localhost:8080/api/v1/user
 	// 访问路径示例: GET http:/localhost:8080/api/v1/product
 
 	// 创建一个路由组/api/v1，处理GET请求
-	// curl -X GET http://localhost:8080/api/v1/user
-	// curl -X GET http://localhost:8080/api/v1/product
-	v1 := r.Group("/api/v1")
+	// enforcer.Group 与 r.Group 用法相同，另外会记录路由需要的权限；With 为单个路由追加权限（RequirePermission 中间件）
+	// curl -X GET http://localhost:8080/api/v1/user -b cookie.txt
+	// curl -X GET http://localhost:8080/api/v1/product -b cookie.txt
+	v1 := enforcer.Group(r, "/api/v1")
 	// {}是书写规范
 	{
-		v1.GET("/user", func(c *gin.Context) {
+		v1.With("user:read").GET("/user", func(c *gin.Context) {
 			c.String(http.StatusOK, "User Endpoint")
 		})
 
-		v1.GET("/product", func(c *gin.Context) {
+		v1.With("product:read").GET("/product", func(c *gin.Context) {
 			c.String(http.StatusOK, "Product Endpoint")
 		})
 	}
 
 	// 创建第二个路由组，处理POST请求
-	// curl -X POST http://localhost:8080/api/v2/login
-	// curl -X POST http://localhost:8080/api/v2/submit
-	v2 := r.Group("/api/v2")
+	// 路由组可以单独挂中间件，这里给修改类接口加上 CSRF 防护（双重提交 Cookie，不需要 Session，令牌用密钥环派生的密钥签名）：
+	// 先 GET /api/v2/csrf 拿到令牌（同时写入 csrf_token Cookie），POST 时在 X-CSRF-Token 请求头中带上
+	// curl -c cookie.txt http://localhost:8080/api/v2/csrf
+	// 策略中列出的用户（alice、bob）在第一次启动时创建，初始密码打印在日志中；其他用户先注册，拥有 default_roles
+	// curl -b cookie.txt -c cookie.txt -X POST http://localhost:8080/api/v2/register -H 'X-CSRF-Token: <csrf_token>' -d name=carol -d password=carol-password
+	// curl -b cookie.txt -c cookie.txt -X POST http://localhost:8080/api/v2/login -H 'X-CSRF-Token: <csrf_token>' -d name=bob -d password=<初始密码>
+	// curl -b cookie.txt -X POST http://localhost:8080/api/v2/submit -H 'X-CSRF-Token: <csrf_token>'
+	v2 := enforcer.Group(r, "/api/v2")
+	v2.Use(csrf.Middleware(csrf.Options{Key: keyring.SigningKey(session.PurposeCSRF)}))
 	{
+		v2.GET("/csrf", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"csrf_token": csrf.Token(c)})
+		})
 		// func (group *gin.RouterGroup) POST(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes
+		v2.POST("/register", register)
 		v2.POST("/login", login)
-		v2.POST("/submit", submit)
+		v2.With("product:write").POST("/submit", submit)
 	}
 
+	// 路由权限清单：哪些路由需要哪些权限
+	// curl http://localhost:8080/api/rbac/routes -b cookie.txt
+	admin := enforcer.Group(r, "/api/rbac", "rbac:read")
+	admin.GET("/routes", enforcer.Introspect)
+
 	r.Run(":8080") // listen and serve on
 }
 
+// identify 从签名 Cookie 中取出用户名存入 c.Set("username")，没有或无效时按未登录处理
+func identify(c *gin.Context) {
+	if username, err := cookies.Get(c, "username"); err == nil {
+		c.Set("username", username)
+	}
+	c.Next()
+}
+
+// seedUsers 创建策略中列出但还不存在的用户，随机生成初始密码并打印一次；已存在的用户不受影响
+func seedUsers(ctx context.Context, policy *rbac.Policy) error {
+	for name := range policy.Users {
+		password := base64.RawURLEncoding.EncodeToString(securecookie.GenerateRandomKey(12))
+		_, err := authService.Register(ctx, name, password)
+		switch {
+		case errors.Is(err, auth.ErrUserExists):
+		case err != nil:
+			return fmt.Errorf("%s: %w", name, err)
+		default:
+			log.Printf("created user %s with initial password %s", name, password)
+		}
+	}
+	return nil
+}
+
+// register 注册新用户；策略中列出的用户名启动时已创建，不能被抢注
+func register(c *gin.Context) {
+	_, err := authService.Register(c.Request.Context(), c.PostForm("name"), c.PostForm("password"))
+	switch {
+	case errors.Is(err, auth.ErrUserExists):
+		c.String(http.StatusConflict, "用户名已被注册")
+	case errors.Is(err, auth.ErrInvalidUsername):
+		c.String(http.StatusBadRequest, "用户名只能包含字母、数字、下划线、点和横线，长度3-32")
+	case errors.Is(err, auth.ErrWeakPassword):
+		c.String(http.StatusBadRequest, "密码至少%d位", authService.MinPasswordLen)
+	case err != nil:
+		c.String(http.StatusInternalServerError, "注册失败")
+	default:
+		c.String(http.StatusCreated, "注册成功")
+	}
+}
+
+// login 校验用户名密码后，把用户名写入签名 Cookie；连续失败会被临时锁定
 func login(c *gin.Context) {
-	name := c.DefaultPostForm("name", "Guest")
-	c.String(http.StatusOK, fmt.Sprintf("Login, %s!", name))
+	user, err := loginGuard.Authenticate(c.Request.Context(), authService, c.PostForm("name"), c.PostForm("password"), c.ClientIP(), nil)
+	var locked *auth.LockedError
+	switch {
+	case errors.As(err, &locked):
+		c.Header("Retry-After", strconv.Itoa(int(locked.RetryAfter.Seconds())+1))
+		c.String(http.StatusTooManyRequests, "登录失败次数过多，请稍后再试")
+		return
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		c.String(http.StatusUnauthorized, "用户名或密码错误")
+		return
+	case err != nil:
+		c.String(http.StatusInternalServerError, "登录失败")
+		return
+	}
+	// 保存存储中的用户名（而不是表单中的原样输入），与策略中的用户名一致
+	if err = cookies.Set(c, "username", user.Username, 3600, "/", "", false, true); err != nil {
+		c.String(http.StatusInternalServerError, "login err: %s", err.Error())
+		return
+	}
+	c.String(http.StatusOK, fmt.Sprintf("Login, %s!", user.Username))
 }
 
 func submit(c *gin.Context) {
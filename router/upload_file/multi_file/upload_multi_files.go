@@ -4,14 +4,28 @@ import (
 	"fmt"
 	"net/http"
 
+	"gin_learn/session_control/csrf"
+
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	csrfKey, err := csrf.DefaultKey()
+	if err != nil {
+		panic("加载CSRF密钥失败: " + err.Error())
+	}
 	r := gin.Default()
 
 	// 多文件上传处理，先限制下上传大小
 	r.MaxMultipartMemory = 8 << 20 // 8 MiB, 多文件上传时gin有默认限制为32 MiB，通过设置MaxMultipartMemory可以修改该限制
+
+	// 上传表单带 CSRF 令牌，浏览器打开 http://localhost:8000/ 上传
+	r.Use(csrf.Middleware(csrf.Options{Key: csrfKey}))
+	r.LoadHTMLFiles("./router/upload_file/multi_file/upload_files.html")
+	r.GET("/", func(c *gin.Context) {
+		c.HTML(http.StatusOK, "upload_files.html", gin.H{"csrfField": csrf.TemplateField(c)})
+	})
+
 	r.POST("/upload", func(c *gin.Context) {
 		// func (c *gin.Context) MultipartForm() (*multipart.Form, error)
 		form, err := c.MultipartForm()
@@ -4,12 +4,26 @@ import (
 	"fmt"
 	"net/http"
 
+	"gin_learn/session_control/csrf"
+
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	csrfKey, err := csrf.DefaultKey()
+	if err != nil {
+		panic("加载CSRF密钥失败: " + err.Error())
+	}
 	r := gin.Default()
 
+	// 上传表单由服务端渲染，模板中的 {{ .csrfField }} 输出带 CSRF 令牌的隐藏字段
+	// 浏览器打开 http://localhost:8080/ 上传
+	r.Use(csrf.Middleware(csrf.Options{Key: csrfKey}))
+	r.LoadHTMLFiles("./router/upload_file/single_file/upload_file.html")
+	r.GET("/", func(c *gin.Context) {
+		c.HTML(http.StatusOK, "upload_file.html", gin.H{"csrfField": csrf.TemplateField(c)})
+	})
+
 	// 单文件上传
 	// 访问路径示例: POST http:/localhost:8080/upload
 	r.POST("/upload", func(c *gin.Context) {
@@ -3,18 +3,34 @@ package main
 import (
 	"net/http"
 
+	"gin_learn/session_control/csrf"
+
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	csrfKey, err := csrf.DefaultKey()
+	if err != nil {
+		panic("加载CSRF密钥失败: " + err.Error())
+	}
 	r := gin.Default()
 
+	// 限制上传文件的大小为8MB
+	// CSRF 中间件会读取表单中的 _csrf 字段（解析整个 multipart 请求体），所以大小限制要放在它前面
+	r.Use(func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, 8<<20) // 8MB, 1MB 等于 2的20次方字节
+		c.Next()
+	})
+	r.Use(csrf.Middleware(csrf.Options{Key: csrfKey}))
+	r.LoadHTMLFiles("./router/upload_file/single_file/upload_file.html")
+	// 浏览器打开 http://localhost:8080/ 上传
+	r.GET("/", func(c *gin.Context) {
+		c.HTML(http.StatusOK, "upload_file.html", gin.H{"csrfField": csrf.TemplateField(c)})
+	})
+
 	// 有的用户上传文件需要限制上传文件的类型以及上传文件的大小
 	// 访问路径示例: POST http:/localhost:8080/upload
 	r.POST("/upload", func(c *gin.Context) {
-		// 限制上传文件的大小为8MB
-		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, 8<<20) // 8MB, 1MB 等于 2的20次方字节
-
 		// 获取上传的文件
 		file, err := c.FormFile("file")
 		if err != nil {
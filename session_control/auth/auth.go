@@ -0,0 +1,164 @@
+package auth
+
+/*
+用户认证：用户存储（UserStore）+ 密码哈希（argon2id，兼容校验 bcrypt）+ 注册/登录/改密。
+
+	users, _ := auth.Open("demo")    // 默认使用 bbolt 文件 ./session_control/data/users-demo.db，见 Open
+	svc := auth.NewService(users)
+	user, err := svc.Authenticate(ctx, "root", "password")
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gin_learn/session_control/kv"
+)
+
+var (
+	// ErrInvalidCredentials 用户名或密码错误；不区分用户不存在和密码错误，避免泄露用户是否存在
+	ErrInvalidCredentials = errors.New("auth: invalid username or password")
+	// ErrWeakPassword 密码不满足长度要求
+	ErrWeakPassword = errors.New("auth: password too short")
+	// ErrInvalidUsername 用户名格式不合法
+	ErrInvalidUsername = errors.New("auth: invalid username")
+)
+
+// DefaultUsersDir 默认的用户数据库目录，每个示例使用其中自己的 bbolt 文件 users-<name>.db
+const DefaultUsersDir = "./session_control/data"
+
+// usernamePattern 用户名：3-32 位字母、数字、下划线、点或横线
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{3,32}$`)
+
+// Open 打开持久化用户存储，name 为示例名：
+//   - 默认使用 bbolt 文件 ./session_control/data/users-<name>.db（AUTH_USERS_DB 可修改）。
+//     bbolt 对文件加独占锁，多个进程不能同时打开同一个文件，所以每个示例一个文件
+//   - 多个同时运行的示例要共享用户时，设置 AUTH_USERS_BACKEND=redis 和 AUTH_USERS_ADDR（如 go run ./session_control/respd 启动的 127.0.0.1:6380）
+func Open(name string) (*KVUserStore, error) {
+	cfg := kv.Config{Backend: "bolt", Path: filepath.Join(DefaultUsersDir, "users-"+name+".db")}
+	for env, p := range map[string]*string{
+		"AUTH_USERS_BACKEND":  &cfg.Backend,
+		"AUTH_USERS_DB":       &cfg.Path,
+		"AUTH_USERS_ADDR":     &cfg.Addr,
+		"AUTH_USERS_PASSWORD": &cfg.Password,
+	} {
+		if v := os.Getenv(env); v != "" {
+			*p = v
+		}
+	}
+	if cfg.Backend == "bolt" {
+		if err := os.MkdirAll(filepath.Dir(cfg.Path), 0o700); err != nil {
+			return nil, err
+		}
+	}
+	backend, err := kv.Open(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("auth: open users store (%s): %w", cfg.Backend, err)
+	}
+	return NewKVUserStore(backend), nil
+}
+
+// Service 注册、登录和修改密码
+type Service struct {
+	Users          UserStore
+	Hasher         Hasher // 新密码使用的哈希算法
+	MinPasswordLen int
+
+	// dummyHash 用户不存在时也做一次哈希校验，使响应时间与密码错误时一致
+	dummyHash string
+}
+
+// NewService 创建认证服务，默认使用 argon2id，密码至少 8 位
+func NewService(users UserStore) *Service {
+	s := &Service{Users: users, Hasher: Argon2id{Params: DefaultArgon2Params}, MinPasswordLen: 8}
+	s.dummyHash, _ = s.Hasher.Hash("dummy-password")
+	return s
+}
+
+// Authenticate 校验用户名和密码，失败时统一返回 ErrInvalidCredentials；
+// 校验通过且哈希不是当前算法生成的（如旧的 bcrypt 哈希）时，顺带用当前算法重新哈希
+func (s *Service) Authenticate(ctx context.Context, username, password string) (User, error) {
+	u, err := s.Users.ByUsername(ctx, username)
+	if errors.Is(err, ErrUserNotFound) {
+		_, _ = VerifyPassword(s.dummyHash, password)
+		return User{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return User{}, err
+	}
+	ok, err := VerifyPassword(u.PasswordHash, password)
+	if err != nil {
+		return User{}, err
+	}
+	if !ok {
+		return User{}, ErrInvalidCredentials
+	}
+	if s.needsRehash(u.PasswordHash) {
+		if h, err := s.Hasher.Hash(password); err == nil {
+			_ = s.Users.UpdatePassword(ctx, u.ID, h)
+		}
+	}
+	return u, nil
+}
+
+// Register 注册新用户
+func (s *Service) Register(ctx context.Context, username, password string) (User, error) {
+	if !usernamePattern.MatchString(username) {
+		return User{}, ErrInvalidUsername
+	}
+	if err := s.checkPassword(password); err != nil {
+		return User{}, err
+	}
+	h, err := s.Hasher.Hash(password)
+	if err != nil {
+		return User{}, err
+	}
+	return s.Users.Create(ctx, username, h)
+}
+
+// ChangePassword 校验旧密码后修改密码，旧密码错误时返回 ErrInvalidCredentials
+func (s *Service) ChangePassword(ctx context.Context, id int64, oldPassword, newPassword string) error {
+	u, err := s.Users.ByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	ok, err := VerifyPassword(u.PasswordHash, oldPassword)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidCredentials
+	}
+	if err = s.checkPassword(newPassword); err != nil {
+		return err
+	}
+	h, err := s.Hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+	return s.Users.UpdatePassword(ctx, id, h)
+}
+
+func (s *Service) checkPassword(password string) error {
+	if len([]rune(password)) < s.MinPasswordLen {
+		return fmt.Errorf("%w: at least %d characters", ErrWeakPassword, s.MinPasswordLen)
+	}
+	return nil
+}
+
+// needsRehash 哈希的算法与当前 Hasher 不同
+func (s *Service) needsRehash(encoded string) bool {
+	switch s.Hasher.(type) {
+	case Argon2id:
+		return !strings.HasPrefix(encoded, "$argon2id$")
+	case Bcrypt:
+		return strings.HasPrefix(encoded, "$argon2id$")
+	default:
+		return false
+	}
+}
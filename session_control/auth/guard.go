@@ -0,0 +1,251 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gin_learn/session_control/kv"
+
+	"go.uber.org/zap"
+)
+
+// ErrCaptchaRequired 失败次数达到阈值，本次登录需要提供正确的验证码
+var ErrCaptchaRequired = errors.New("auth: captcha required")
+
+// LockedError 账号或 IP 被临时锁定
+type LockedError struct {
+	Scope      string // "account" 或 "ip"
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("auth: %s locked, retry after %s", e.Scope, e.RetryAfter.Round(time.Second))
+}
+
+// GuardConfig 防暴力破解配置
+type GuardConfig struct {
+	CaptchaAfter int           // 连续失败多少次后要求验证码，0 表示不启用验证码
+	LockAfter    int           // 连续失败多少次后锁定
+	IPLockAfter  int           // 同一 IP 失败多少次后锁定（不区分账号，防止撞库）
+	BaseLockout  time.Duration // 首次锁定时长，之后每多失败一次翻倍（指数退避）
+	MaxLockout   time.Duration // 锁定时长上限
+	Window       time.Duration // 失败计数的保留时间，超过这么久没有失败则清零
+}
+
+// DefaultGuardConfig 默认配置：3 次失败后要求验证码，5 次锁定 1 分钟，之后每次翻倍，最长 1 小时
+var DefaultGuardConfig = GuardConfig{
+	CaptchaAfter: 3,
+	LockAfter:    5,
+	IPLockAfter:  20,
+	BaseLockout:  time.Minute,
+	MaxLockout:   time.Hour,
+	Window:       15 * time.Minute,
+}
+
+// Guard 按账号和 IP 统计登录失败次数，达到阈值后要求验证码、临时锁定，锁定时写审计日志。
+// 计数的读-改-写由 mu 保证在单进程内是原子的
+type Guard struct {
+	Config GuardConfig
+	Logger *zap.Logger // 审计日志，默认 zap.L()
+
+	kv kv.Store
+	mu sync.Mutex
+}
+
+// failures 某个账号或 IP 的失败记录
+type failures struct {
+	Count       int       `json:"count"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+// NewGuard 创建防暴力破解器，计数保存在 backend 中（多副本部署时使用 redis 共享计数）
+func NewGuard(backend kv.Store, cfg GuardConfig) *Guard {
+	return &Guard{Config: cfg, kv: backend}
+}
+
+// Authenticate 带防暴力破解的登录：被锁定时返回 *LockedError，需要验证码但 captcha 为 nil 或返回 false 时返回 ErrCaptchaRequired，
+// 其余与 Service.Authenticate 相同；captcha 只在需要时调用
+//
+// 校验密码（argon2 较慢）之前先在锁内检查并预占一次失败计数，并发的猜测请求会依次看到前面请求的计数，
+// 无法同时通过锁定和验证码的检查；密码正确或验证码错误时再撤销预占
+func (g *Guard) Authenticate(ctx context.Context, svc *Service, username, password, ip string, captcha func() bool) (User, error) {
+	r, err := g.reserve(ctx, username, ip)
+	if err != nil {
+		return User{}, err
+	}
+	if r.captcha && (captcha == nil || !captcha()) {
+		if err = g.release(ctx, username, ip, r, false); err != nil {
+			return User{}, err
+		}
+		return User{}, ErrCaptchaRequired
+	}
+
+	u, err := svc.Authenticate(ctx, username, password)
+	if errors.Is(err, ErrInvalidCredentials) {
+		g.audit(username, ip, r)
+		return User{}, err
+	}
+	if err != nil {
+		if rerr := g.release(ctx, username, ip, r, false); rerr != nil {
+			return User{}, rerr
+		}
+		return User{}, err
+	}
+	// 登录成功清零账号计数，IP 计数只撤销本次预占，其余按 Window 自然过期
+	return u, g.release(ctx, username, ip, r, true)
+}
+
+// CaptchaRequired 该账号下次登录是否需要验证码，用于前端提前展示验证码
+func (g *Guard) CaptchaRequired(ctx context.Context, username string) bool {
+	f, err := g.get(ctx, accountKey(username))
+	return err == nil && g.Config.CaptchaAfter > 0 && f.Count >= g.Config.CaptchaAfter
+}
+
+// reservation 预占的一次失败
+type reservation struct {
+	captcha bool     // 预占前的失败次数已达到验证码阈值
+	account failures // 预占后的账号计数
+	ip      failures // 预占后的 IP 计数
+	// 本次预占触发的锁定时长，0 表示没有触发
+	accountLockout, ipLockout time.Duration
+}
+
+// reserve 在锁内检查锁定状态，并把账号和 IP 的失败次数各加一
+func (g *Guard) reserve(ctx context.Context, username, ip string) (reservation, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var r reservation
+	now := time.Now()
+	account, err := g.get(ctx, accountKey(username))
+	if err != nil {
+		return r, err
+	}
+	byIP, err := g.get(ctx, ipKey(ip))
+	if err != nil {
+		return r, err
+	}
+	if now.Before(account.LockedUntil) {
+		return r, &LockedError{Scope: "account", RetryAfter: account.LockedUntil.Sub(now)}
+	}
+	if now.Before(byIP.LockedUntil) {
+		return r, &LockedError{Scope: "ip", RetryAfter: byIP.LockedUntil.Sub(now)}
+	}
+	r.captcha = g.Config.CaptchaAfter > 0 && account.Count >= g.Config.CaptchaAfter
+	if r.account, r.accountLockout, err = g.add(ctx, accountKey(username), account, 1, g.Config.LockAfter); err != nil {
+		return r, err
+	}
+	if r.ip, r.ipLockout, err = g.add(ctx, ipKey(ip), byIP, 1, g.Config.IPLockAfter); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// release 撤销预占 r：clearAccount 为 true（登录成功）时删除账号计数，否则账号和 IP 计数各减一
+func (g *Guard) release(ctx context.Context, username, ip string, r reservation, clearAccount bool) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if clearAccount {
+		if err := g.kv.Delete(ctx, accountKey(username)); err != nil {
+			return err
+		}
+	} else if err := g.undo(ctx, accountKey(username), g.Config.LockAfter, r.accountLockout > 0); err != nil {
+		return err
+	}
+	return g.undo(ctx, ipKey(ip), g.Config.IPLockAfter, r.ipLockout > 0)
+}
+
+// undo 计数减一；locked 表示锁定是这次预占触发的（预占前没有生效中的锁定），一并解除
+func (g *Guard) undo(ctx context.Context, key string, threshold int, locked bool) error {
+	f, err := g.get(ctx, key)
+	if err != nil || f.Count == 0 {
+		return err
+	}
+	if locked {
+		f.LockedUntil = time.Time{}
+	}
+	_, _, err = g.add(ctx, key, f, -1, threshold)
+	return err
+}
+
+// audit 密码错误，预占的失败生效；触发锁定时写审计日志
+func (g *Guard) audit(username, ip string, r reservation) {
+	for _, l := range []struct {
+		scope    string
+		failures int
+		lockout  time.Duration
+	}{
+		{"account", r.account.Count, r.accountLockout},
+		{"ip", r.ip.Count, r.ipLockout},
+	} {
+		if l.lockout > 0 {
+			g.logger().Warn("login locked", zap.String("username", username), zap.String("ip", ip),
+				zap.String("scope", l.scope), zap.Int("failures", l.failures), zap.Duration("lockout", l.lockout))
+		}
+	}
+}
+
+// add 把计数加上 delta 并保存：达到 threshold 时锁定，返回本次触发的锁定时长；撤销后低于阈值时解除锁定。调用方需持有 g.mu
+func (g *Guard) add(ctx context.Context, key string, f failures, delta, threshold int) (failures, time.Duration, error) {
+	f.Count += delta
+	var lockout time.Duration
+	switch {
+	case threshold <= 0:
+	case delta > 0 && f.Count >= threshold:
+		lockout = g.lockout(f.Count - threshold)
+		f.LockedUntil = time.Now().Add(lockout)
+	case f.Count < threshold:
+		f.LockedUntil = time.Time{}
+	}
+	b, err := json.Marshal(f)
+	if err != nil {
+		return f, 0, err
+	}
+	// 锁定期间计数不能过期，否则解锁后退避时长会被重置
+	ttl := g.Config.Window
+	if d := time.Until(f.LockedUntil) + g.Config.Window; d > ttl {
+		ttl = d
+	}
+	return f, lockout, g.kv.Set(ctx, key, b, ttl)
+}
+
+// lockout 第 n 次（从 0 开始）超过阈值时的锁定时长：BaseLockout * 2^n，不超过 MaxLockout
+func (g *Guard) lockout(n int) time.Duration {
+	d := g.Config.BaseLockout
+	for i := 0; i < n && d < g.Config.MaxLockout; i++ {
+		d *= 2
+	}
+	return min(d, g.Config.MaxLockout)
+}
+
+func (g *Guard) get(ctx context.Context, key string) (failures, error) {
+	var f failures
+	b, err := g.kv.Get(ctx, key)
+	if errors.Is(err, kv.ErrNotFound) {
+		return f, nil
+	}
+	if err != nil {
+		return f, err
+	}
+	err = json.Unmarshal(b, &f)
+	return f, err
+}
+
+func (g *Guard) logger() *zap.Logger {
+	if g.Logger != nil {
+		return g.Logger
+	}
+	return zap.L()
+}
+
+func accountKey(username string) string {
+	return "login_fail:user:" + strings.ToLower(username)
+}
+
+func ipKey(ip string) string {
+	return "login_fail:ip:" + ip
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"gin_learn/session_control/kv"
+)
+
+// testArgon2Params 测试用的 argon2id 参数，足够慢使并发请求在校验密码时重叠，又不会占用太多内存
+var testArgon2Params = Argon2Params{Memory: 8 * 1024, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32}
+
+func newTestGuard(t *testing.T, cfg GuardConfig) (*Guard, *Service) {
+	t.Helper()
+	backend := kv.NewMemory(time.Hour)
+	t.Cleanup(func() { _ = backend.Close() })
+	svc := NewService(NewKVUserStore(backend))
+	svc.Hasher = Argon2id{Params: testArgon2Params}
+	if _, err := svc.Register(context.Background(), "alice", "correct-password"); err != nil {
+		t.Fatal(err)
+	}
+	return NewGuard(backend, cfg), svc
+}
+
+func TestGuardLockout(t *testing.T) {
+	ctx := context.Background()
+	cfg := GuardConfig{LockAfter: 3, IPLockAfter: 100, BaseLockout: time.Minute, MaxLockout: time.Hour, Window: time.Hour}
+	g, svc := newTestGuard(t, cfg)
+
+	for i := range cfg.LockAfter {
+		if _, err := g.Authenticate(ctx, svc, "alice", "wrong", "10.0.0.1", nil); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %d = %v, want ErrInvalidCredentials", i+1, err)
+		}
+	}
+	// 锁定后正确的密码也不能登录，换 IP 也不行
+	_, err := g.Authenticate(ctx, svc, "alice", "correct-password", "10.0.0.2", nil)
+	var locked *LockedError
+	if !errors.As(err, &locked) || locked.Scope != "account" {
+		t.Fatalf("after %d failures = %v, want account LockedError", cfg.LockAfter, err)
+	}
+	if locked.RetryAfter <= 0 || locked.RetryAfter > cfg.BaseLockout {
+		t.Fatalf("RetryAfter = %s, want (0, %s]", locked.RetryAfter, cfg.BaseLockout)
+	}
+}
+
+func TestGuardSuccessResetsAccount(t *testing.T) {
+	ctx := context.Background()
+	cfg := GuardConfig{LockAfter: 3, IPLockAfter: 100, BaseLockout: time.Minute, MaxLockout: time.Hour, Window: time.Hour}
+	g, svc := newTestGuard(t, cfg)
+
+	for range cfg.LockAfter - 1 {
+		_, _ = g.Authenticate(ctx, svc, "alice", "wrong", "10.0.0.1", nil)
+	}
+	if _, err := g.Authenticate(ctx, svc, "alice", "correct-password", "10.0.0.1", nil); err != nil {
+		t.Fatalf("correct password = %v", err)
+	}
+	// 计数已清零，再错 LockAfter-1 次也不会锁定
+	for range cfg.LockAfter - 1 {
+		_, _ = g.Authenticate(ctx, svc, "alice", "wrong", "10.0.0.1", nil)
+	}
+	if _, err := g.Authenticate(ctx, svc, "alice", "correct-password", "10.0.0.1", nil); err != nil {
+		t.Fatalf("correct password after reset = %v", err)
+	}
+}
+
+func TestGuardCaptchaThreshold(t *testing.T) {
+	ctx := context.Background()
+	cfg := GuardConfig{CaptchaAfter: 2, LockAfter: 5, IPLockAfter: 100, BaseLockout: time.Minute, MaxLockout: time.Hour, Window: time.Hour}
+	g, svc := newTestGuard(t, cfg)
+
+	for range cfg.CaptchaAfter {
+		_, _ = g.Authenticate(ctx, svc, "alice", "wrong", "10.0.0.1", nil)
+	}
+	if !g.CaptchaRequired(ctx, "alice") {
+		t.Fatal("CaptchaRequired = false after reaching threshold")
+	}
+	// 验证码错误不计入失败次数，多少次都不会锁定
+	for range cfg.LockAfter * 2 {
+		if _, err := g.Authenticate(ctx, svc, "alice", "wrong", "10.0.0.1", func() bool { return false }); !errors.Is(err, ErrCaptchaRequired) {
+			t.Fatalf("wrong captcha = %v, want ErrCaptchaRequired", err)
+		}
+	}
+	if _, err := g.Authenticate(ctx, svc, "alice", "correct-password", "10.0.0.1", func() bool { return true }); err != nil {
+		t.Fatalf("correct captcha and password = %v", err)
+	}
+}
+
+// TestGuardConcurrentGuesses 并发猜测不能绕过锁定和验证码阈值：最多 LockAfter 次真正校验了密码
+func TestGuardConcurrentGuesses(t *testing.T) {
+	ctx := context.Background()
+	for _, tt := range []struct {
+		name    string
+		cfg     GuardConfig
+		checked int // 最多有几次请求校验了密码
+	}{
+		{"account", GuardConfig{LockAfter: 3, IPLockAfter: 100, BaseLockout: time.Minute, MaxLockout: time.Hour, Window: time.Hour}, 3},
+		{"ip", GuardConfig{LockAfter: 100, IPLockAfter: 3, BaseLockout: time.Minute, MaxLockout: time.Hour, Window: time.Hour}, 3},
+		{"captcha", GuardConfig{CaptchaAfter: 2, LockAfter: 100, IPLockAfter: 100, BaseLockout: time.Minute, MaxLockout: time.Hour, Window: time.Hour}, 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			g, svc := newTestGuard(t, tt.cfg)
+			var (
+				wg      sync.WaitGroup
+				mu      sync.Mutex
+				checked int
+			)
+			for range 20 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := g.Authenticate(ctx, svc, "alice", "wrong", "10.0.0.1", nil)
+					if errors.Is(err, ErrInvalidCredentials) {
+						mu.Lock()
+						checked++
+						mu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+			if checked > tt.checked {
+				t.Fatalf("%d concurrent guesses reached the password check, want at most %d", checked, tt.checked)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gin_learn/session_control/kv"
+)
+
+var (
+	// ErrUserNotFound 用户不存在
+	ErrUserNotFound = errors.New("auth: user not found")
+	// ErrUserExists 用户名已被注册
+	ErrUserExists = errors.New("auth: username already taken")
+)
+
+// User 用户
+type User struct {
+	ID           int64     `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"password_hash"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// UserStore 用户存储
+type UserStore interface {
+	// Create 创建用户并分配 ID，用户名已存在时返回 ErrUserExists
+	Create(ctx context.Context, username, passwordHash string) (User, error)
+	// ByUsername 按用户名查找（不区分大小写），不存在时返回 ErrUserNotFound
+	ByUsername(ctx context.Context, username string) (User, error)
+	// ByID 按 ID 查找，不存在时返回 ErrUserNotFound
+	ByID(ctx context.Context, id int64) (User, error)
+	// UpdatePassword 更新密码哈希
+	UpdatePassword(ctx context.Context, id int64, passwordHash string) error
+}
+
+// 用户数据在 kv 中的 key
+const (
+	userSeqKey     = "user_seq"       // 最后分配的用户ID
+	userIDPrefix   = "user_id:"       // user_id:<id> -> User JSON
+	userNamePrefix = "user_username:" // user_username:<小写用户名> -> id
+)
+
+// KVUserStore 基于 kv.Store 的用户存储：backend 为 memory 时是内存存储，为 bolt/file/redis 时持久化
+// ID 分配和用户名唯一由 kv 的 Incr/SetNX 保证；mu 只串行化本进程内的 UpdatePassword
+type KVUserStore struct {
+	kv kv.Store
+	mu sync.Mutex
+}
+
+// NewKVUserStore 创建基于 kv.Store 的用户存储
+func NewKVUserStore(backend kv.Store) *KVUserStore {
+	return &KVUserStore{kv: backend}
+}
+
+// NewMemoryUserStore 创建内存用户存储，进程退出后数据丢失
+func NewMemoryUserStore() *KVUserStore {
+	return NewKVUserStore(kv.NewMemory(kv.DefaultJanitorInterval))
+}
+
+// Create 先用 Incr 分配 ID 并写入用户数据，再用 SetNX 占用用户名，占用失败时删除刚写入的数据；
+// 两步都由存储保证原子性，共享同一个 redis 的多个进程同时注册也不会重复分配 ID 或互相覆盖用户名
+func (s *KVUserStore) Create(ctx context.Context, username, passwordHash string) (User, error) {
+	nameKey := userNamePrefix + strings.ToLower(username)
+	if _, err := s.kv.Get(ctx, nameKey); err == nil {
+		return User{}, ErrUserExists
+	} else if !errors.Is(err, kv.ErrNotFound) {
+		return User{}, err
+	}
+
+	id, err := s.kv.Incr(ctx, userSeqKey)
+	if err != nil {
+		return User{}, err
+	}
+	now := time.Now()
+	u := User{ID: id, Username: username, PasswordHash: passwordHash, CreatedAt: now, UpdatedAt: now}
+	if err = s.put(ctx, u); err != nil {
+		return User{}, err
+	}
+	// 用户名指向的数据总是已经写入，ByUsername 不会查到悬空的 ID
+	ok, err := s.kv.SetNX(ctx, nameKey, []byte(strconv.FormatInt(id, 10)), 0)
+	if err != nil || !ok {
+		_ = s.kv.Delete(ctx, userIDPrefix+strconv.FormatInt(id, 10))
+	}
+	if err != nil {
+		return User{}, err
+	}
+	if !ok {
+		return User{}, ErrUserExists
+	}
+	return u, nil
+}
+
+func (s *KVUserStore) ByUsername(ctx context.Context, username string) (User, error) {
+	b, err := s.kv.Get(ctx, userNamePrefix+strings.ToLower(username))
+	if errors.Is(err, kv.ErrNotFound) {
+		return User{}, ErrUserNotFound
+	}
+	if err != nil {
+		return User{}, err
+	}
+	id, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return User{}, err
+	}
+	return s.ByID(ctx, id)
+}
+
+func (s *KVUserStore) ByID(ctx context.Context, id int64) (User, error) {
+	b, err := s.kv.Get(ctx, userIDPrefix+strconv.FormatInt(id, 10))
+	if errors.Is(err, kv.ErrNotFound) {
+		return User{}, ErrUserNotFound
+	}
+	if err != nil {
+		return User{}, err
+	}
+	var u User
+	err = json.Unmarshal(b, &u)
+	return u, err
+}
+
+func (s *KVUserStore) UpdatePassword(ctx context.Context, id int64, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, err := s.ByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	u.PasswordHash = passwordHash
+	u.UpdatedAt = time.Now()
+	return s.put(ctx, u)
+}
+
+// Close 关闭底层存储
+func (s *KVUserStore) Close() error {
+	return s.kv.Close()
+}
+
+func (s *KVUserStore) put(ctx context.Context, u User) error {
+	b, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	return s.kv.Set(ctx, userIDPrefix+strconv.FormatInt(u.ID, 10), b, 0)
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"gin_learn/session_control/kv"
+)
+
+// TestKVUserStoreConcurrentCreate 两个 KVUserStore 共享同一个存储（相当于两个进程共用一个 redis），
+// 并发注册时 ID 不重复，同一个用户名只有一个能注册成功
+func TestKVUserStoreConcurrentCreate(t *testing.T) {
+	ctx := context.Background()
+	backend := kv.NewMemory(time.Hour)
+	t.Cleanup(func() { _ = backend.Close() })
+	stores := []*KVUserStore{NewKVUserStore(backend), NewKVUserStore(backend)}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		ids     = make(map[int64]string)
+		winners = make(map[string]int)
+	)
+	for i := range 40 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name := fmt.Sprintf("user%d", i%10)
+			u, err := stores[i%2].Create(ctx, name, "hash")
+			if errors.Is(err, ErrUserExists) {
+				return
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if other, dup := ids[u.ID]; dup {
+				t.Errorf("id %d assigned to both %s and %s", u.ID, other, name)
+			}
+			ids[u.ID] = name
+			winners[name]++
+		}()
+	}
+	wg.Wait()
+
+	for i := range 10 {
+		name := fmt.Sprintf("user%d", i)
+		if winners[name] != 1 {
+			t.Fatalf("%s registered %d times, want 1", name, winners[name])
+		}
+		u, err := stores[0].ByUsername(ctx, name)
+		if err != nil || ids[u.ID] != name {
+			t.Fatalf("ByUsername(%s) = %+v, %v", name, u, err)
+		}
+	}
+}
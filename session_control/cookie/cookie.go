@@ -0,0 +1,178 @@
+package cookie
+
+/*
+签名（HMAC-SHA256）和可选加密（AES-GCM）的 Cookie，过期时间写在值里，客户端改不了也延长不了。
+
+编码格式（整体 base64url）：
+
+	名称绑定的 HMAC 签名（32 字节） | 过期时间（8 字节，Unix 秒） | 值（配置了加密密钥时为 nonce|密文）
+
+签名覆盖 Cookie 名称，把 A Cookie 的值复制到 B Cookie 也会被识别为篡改。
+密钥用 session 包的密钥环为 Cookie 派生的密钥（Keyring.KeyPairs(session.PurposeCookie, now)），第一对签发，其余只用于校验，便于轮换。
+*/
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// ErrMissing 请求中没有该 Cookie
+	ErrMissing = errors.New("cookie: missing")
+	// ErrTampered 签名不匹配、格式错误或无法解密
+	ErrTampered = errors.New("cookie: tampered")
+	// ErrExpired 值中记录的过期时间已过
+	ErrExpired = errors.New("cookie: expired")
+)
+
+// Reason 把 Decode / Get 返回的错误转成响应中的 reason 字段：missing / tampered / expired
+func Reason(err error) string {
+	switch {
+	case errors.Is(err, ErrMissing):
+		return "missing"
+	case errors.Is(err, ErrExpired):
+		return "expired"
+	default:
+		return "tampered"
+	}
+}
+
+const (
+	macLen = sha256.Size
+	expLen = 8
+)
+
+type codec struct {
+	hash  []byte
+	block cipher.AEAD // 为 nil 时只签名不加密
+}
+
+// Codec 按密钥对编码和解码 Cookie 值
+type Codec struct {
+	codecs []codec
+}
+
+// New 用密钥对创建 Codec，参数依次为 hashKey, blockKey, hashKey, blockKey...；
+// blockKey 为空时只签名，否则长度须为 16/24/32（AES-128/192/256）
+func New(keyPairs ...[]byte) (*Codec, error) {
+	if len(keyPairs) == 0 {
+		return nil, errors.New("cookie: no keys")
+	}
+	cd := &Codec{}
+	for i := 0; i < len(keyPairs); i += 2 {
+		if len(keyPairs[i]) == 0 {
+			return nil, fmt.Errorf("cookie: empty hash key at %d", i)
+		}
+		cc := codec{hash: keyPairs[i]}
+		if i+1 < len(keyPairs) && len(keyPairs[i+1]) > 0 {
+			block, err := aes.NewCipher(keyPairs[i+1])
+			if err != nil {
+				return nil, fmt.Errorf("cookie: block key at %d: %w", i+1, err)
+			}
+			if cc.block, err = cipher.NewGCM(block); err != nil {
+				return nil, err
+			}
+		}
+		cd.codecs = append(cd.codecs, cc)
+	}
+	return cd, nil
+}
+
+// Encode 用第一对密钥编码 value，expires 之后 Decode 返回 ErrExpired
+func (cd *Codec) Encode(name, value string, expires time.Time) (string, error) {
+	cc := cd.codecs[0]
+	body := []byte(value)
+	if cc.block != nil {
+		nonce := make([]byte, cc.block.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		// 名称作为附加数据，密文同样不能挪到别的 Cookie 中
+		body = cc.block.Seal(nonce, nonce, body, []byte(name))
+	}
+	buf := make([]byte, macLen+expLen, macLen+expLen+len(body))
+	binary.BigEndian.PutUint64(buf[macLen:], uint64(expires.Unix()))
+	buf = append(buf, body...)
+	copy(buf, cc.mac(name, buf[macLen:]))
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Decode 校验签名和过期时间并返回原值；依次尝试每一对密钥
+func (cd *Codec) Decode(name, encoded string, now time.Time) (string, error) {
+	if encoded == "" {
+		return "", ErrMissing
+	}
+	buf, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(buf) < macLen+expLen {
+		return "", ErrTampered
+	}
+	for _, cc := range cd.codecs {
+		if !hmac.Equal(buf[:macLen], cc.mac(name, buf[macLen:])) {
+			continue
+		}
+		// 签名通过后过期时间才可信
+		if now.Unix() >= int64(binary.BigEndian.Uint64(buf[macLen:])) {
+			return "", ErrExpired
+		}
+		body := buf[macLen+expLen:]
+		if cc.block == nil {
+			return string(body), nil
+		}
+		ns := cc.block.NonceSize()
+		if len(body) < ns {
+			return "", ErrTampered
+		}
+		plain, err := cc.block.Open(nil, body[:ns], body[ns:], []byte(name))
+		if err != nil {
+			return "", ErrTampered
+		}
+		return string(plain), nil
+	}
+	return "", ErrTampered
+}
+
+func (cc codec) mac(name string, data []byte) []byte {
+	m := hmac.New(sha256.New, cc.hash)
+	m.Write([]byte(name))
+	m.Write([]byte{0})
+	m.Write(data)
+	return m.Sum(nil)
+}
+
+// Set 与 c.SetCookie 参数相同，值经过签名（和加密），过期时间取 maxAge 秒后
+func (cd *Codec) Set(c *gin.Context, name, value string, maxAge int, path, domain string, secure, httpOnly bool) error {
+	encoded, err := cd.Encode(name, value, time.Now().Add(time.Duration(maxAge)*time.Second))
+	if err != nil {
+		return err
+	}
+	c.SetCookie(name, encoded, maxAge, path, domain, secure, httpOnly)
+	return nil
+}
+
+// Get 与 c.Cookie 对应，返回校验后的原值；错误为 ErrMissing / ErrTampered / ErrExpired
+func (cd *Codec) Get(c *gin.Context, name string) (string, error) {
+	encoded, err := c.Cookie(name)
+	if errors.Is(err, http.ErrNoCookie) {
+		return "", ErrMissing
+	}
+	if err != nil {
+		return "", ErrTampered
+	}
+	return cd.Decode(name, encoded, time.Now())
+}
+
+// Delete 让浏览器删除 Cookie
+func (cd *Codec) Delete(c *gin.Context, name, path, domain string) {
+	c.SetCookie(name, "", -1, path, domain, false, true)
+}
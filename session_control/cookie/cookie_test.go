@@ -0,0 +1,152 @@
+package cookie
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	hashA  = []byte("0123456789abcdef0123456789abcdef")
+	blockA = []byte("fedcba9876543210fedcba9876543210")
+	hashB  = []byte("abcdefghijklmnopqrstuvwxyz012345")
+)
+
+// flip 改动编码后值中第 i 个字节（负数从末尾数起）
+func flip(t *testing.T, encoded string, i int) string {
+	t.Helper()
+	buf, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i < 0 {
+		i += len(buf)
+	}
+	buf[i] ^= 1
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func TestCodec(t *testing.T) {
+	now := time.Now()
+	for name, keys := range map[string][][]byte{
+		"signed":    {hashA, nil},
+		"encrypted": {hashA, blockA},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cd, err := New(keys...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			encoded, err := cd.Encode("user", "alice", now.Add(time.Hour))
+			if err != nil {
+				t.Fatal(err)
+			}
+			expired, err := cd.Encode("user", "alice", now.Add(-time.Second))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, tt := range []struct {
+				name    string
+				cookie  string
+				encoded string
+				want    error
+			}{
+				{"valid", "user", encoded, nil},
+				{"missing", "user", "", ErrMissing},
+				{"not base64", "user", "!!!", ErrTampered},
+				{"too short", "user", "YWJj", ErrTampered},
+				{"tampered mac", "user", flip(t, encoded, 0), ErrTampered},
+				{"tampered expiry", "user", flip(t, encoded, macLen+expLen-1), ErrTampered},
+				{"tampered value", "user", flip(t, encoded, -1), ErrTampered},
+				{"other cookie name", "admin", encoded, ErrTampered},
+				{"expired", "user", expired, ErrExpired},
+				// 延长过期时间必须改动签名覆盖的字节，同样是篡改
+				{"extended expiry", "user", flip(t, expired, macLen+expLen-1), ErrTampered},
+			} {
+				got, err := cd.Decode(tt.cookie, tt.encoded, now)
+				if !errors.Is(err, tt.want) {
+					t.Fatalf("%s: Decode error = %v, want %v", tt.name, err, tt.want)
+				}
+				if tt.want == nil && got != "alice" {
+					t.Fatalf("%s: Decode = %q, want alice", tt.name, got)
+				}
+			}
+		})
+	}
+}
+
+func TestCodecKeyRotation(t *testing.T) {
+	now := time.Now()
+	old, err := New(hashA, blockA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := old.Encode("user", "alice", now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 新密钥放在前面，旧密钥签发的值仍能校验
+	rotated, err := New(hashB, nil, hashA, blockA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := rotated.Decode("user", encoded, now); err != nil || got != "alice" {
+		t.Fatalf("Decode with rotated keys = %q, %v", got, err)
+	}
+	// 旧密钥删除后视为篡改
+	removed, err := New(hashB, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = removed.Decode("user", encoded, now); !errors.Is(err, ErrTampered) {
+		t.Fatalf("Decode after key removal = %v, want ErrTampered", err)
+	}
+}
+
+func TestCodecGet(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cd, err := New(hashA, blockA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	if err = cd.Set(c, "user", "alice", 60, "/", "", false, true); err != nil {
+		t.Fatal(err)
+	}
+	set := w.Result().Cookies()
+	if len(set) != 1 {
+		t.Fatalf("Set wrote %d cookies, want 1", len(set))
+	}
+
+	for _, tt := range []struct {
+		name   string
+		cookie *http.Cookie
+		want   error
+	}{
+		{"valid", set[0], nil},
+		{"missing", nil, ErrMissing},
+		{"tampered", &http.Cookie{Name: "user", Value: flip(t, set[0].Value, -1)}, ErrTampered},
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.cookie != nil {
+			c.Request.AddCookie(tt.cookie)
+		}
+		got, err := cd.Get(c, "user")
+		if !errors.Is(err, tt.want) {
+			t.Fatalf("%s: Get error = %v, want %v", tt.name, err, tt.want)
+		}
+		if tt.want == nil && got != "alice" {
+			t.Fatalf("%s: Get = %q, want alice", tt.name, got)
+		}
+		if tt.want != nil && Reason(err) != tt.name {
+			t.Fatalf("%s: Reason = %q", tt.name, Reason(err))
+		}
+	}
+}
@@ -8,34 +8,54 @@ login用于设置cookie
 home是访问查看信息的请求
 在请求home之前，先跑中间件代码，检验是否存在cookie
 访问home，会显示错误，因为权限校验未通过
+
+username Cookie 经过签名和加密（session_control/cookie），过期时间写在值里，
+改动 Cookie 内容或过期后都无法通过校验，失败原因在 reason 中返回：missing / tampered / expired。
+密钥与 gin_session.go 共用同一个密钥环（go run ./session_control/keyctl init 生成）。
 */
 
 import (
 	"fmt"
 	"net/http"
+	"time"
+
+	"gin_learn/session_control/cookie"
+	"gin_learn/session_control/session"
 
 	"github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+func AuthMiddleware(cookies *cookie.Codec) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if cookie, err := c.Cookie("username"); err == nil {
-			if cookie != "" {
-				c.Set("username", cookie)
-				c.Next()
-				return // 验证通过，继续处理请求
-			}
+		username, err := cookies.Get(c, "username")
+		if err == nil && username != "" {
+			c.Set("username", username)
+			c.Next()
+			return // 验证通过，继续处理请求
 		}
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "reason": cookie.Reason(err)})
 
 		c.Abort() // 没有验证通过，中止后续处理
 	}
 }
 
 func main() {
+	cfg, err := session.LoadConfig("")
+	if err != nil {
+		panic(err)
+	}
+	keyring, err := session.LoadKeyring(cfg.Keyring)
+	if err != nil {
+		panic("加载Session密钥失败: " + err.Error())
+	}
+	cookies, err := cookie.New(keyring.KeyPairs(session.PurposeCookie, time.Now())...)
+	if err != nil {
+		panic(err)
+	}
+
 	r := gin.Default()
 
-	r.GET("/home", AuthMiddleware(), func(c *gin.Context) {
+	r.GET("/home", AuthMiddleware(cookies), func(c *gin.Context) {
 		username, _ := c.Get("username")
 		c.JSON(http.StatusOK, gin.H{
 			"message": fmt.Sprintf("%s, Welcome to the home page!", username),
@@ -48,7 +68,10 @@ func main() {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Username cannot be empty"})
 			return
 		}
-		c.SetCookie("username", username, 3600, "/", "localhost", false, true)
+		if err := cookies.Set(c, "username", username, 3600, "/", "localhost", false, true); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "设置Cookie失败"})
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Logged in successfully!",
 		})
@@ -0,0 +1,172 @@
+package csrf
+
+/*
+CSRF（跨站请求伪造）防护中间件，两种模式：
+
+  - 同步令牌（synchronizer token）：令牌保存在服务端 Session 中，页面或接口把令牌交给前端，
+    提交时带回来与 Session 中的比对。适合已有 Session 的场景，见 session_control/gin_session.go。
+    中间件只读取 Session，不会为了令牌创建 Session（否则每个匿名请求都要在服务端写一条 Session）：
+    Session 中还没有令牌的访客（如登录前）使用下面的双重提交 Cookie，登录时用 RenewSession / Renew 把新令牌写入 Session。
+  - 双重提交 Cookie（double-submit cookie）：令牌写在一个 JS 可读的 Cookie 中，提交时再放到请求头或表单字段里，
+    服务端只比对两者是否一致，不需要 Session。跨站页面读不到本站 Cookie，也就伪造不出相同的值。
+    配置了 Key 时令牌带 HMAC 签名，防止攻击者通过子域名写入自己生成的 Cookie。
+
+GET / HEAD / OPTIONS / TRACE 不做校验（这些请求不应该修改数据），其余请求从请求头 X-CSRF-Token
+或表单字段 _csrf 中读取令牌，校验失败返回 403 JSON：{"error":"CSRF令牌无效","reason":"csrf_missing|csrf_invalid"}。
+*/
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"gin_learn/session_control/session"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// HeaderName 前端通过请求头提交令牌（AJAX / curl）
+	HeaderName = "X-CSRF-Token"
+	// FieldName 表单通过隐藏字段提交令牌
+	FieldName = "_csrf"
+	// CookieName 双重提交模式下保存令牌的 Cookie
+	CookieName = "csrf_token"
+	// ContextKey 中间件把当前令牌存入 gin.Context 时使用的 key
+	ContextKey = "csrf_token"
+)
+
+// Storage 同步令牌模式下保存令牌的位置，一般是 Session
+type Storage interface {
+	// Load 读取令牌，没有时返回空字符串；不能创建 Session
+	Load(c *gin.Context) (string, error)
+	// Save 保存令牌，只由 Renew 在登录等已经要写 Session 的时候调用
+	Save(c *gin.Context, token string) error
+}
+
+// Options 中间件配置
+type Options struct {
+	// Storage 不为 nil 时 Storage 中有令牌就使用同步令牌模式，否则（以及 Storage 为 nil 时）使用双重提交 Cookie 模式
+	Storage Storage
+	// Key 双重提交模式下给令牌签名的密钥，一般用 DefaultKey；为空时不签名，子域名可以写入任意令牌（cookie tossing）
+	Key []byte
+	// Cookie 属性，仅双重提交模式使用；Cookie 不能是 HttpOnly，前端需要读出来放进请求头
+	Path   string
+	Domain string
+	MaxAge int // 秒，0 表示会话 Cookie
+	Secure bool
+}
+
+// DefaultKey 从 session_control 的密钥环（SESSION_KEYS 环境变量或 SESSION_KEYRING 指向的文件）派生双重提交令牌的签名密钥。
+// 不签名时令牌只是一个随机值，能写本站 Cookie 的同站子域名可以种下自己的令牌再伪造出相同的请求头；
+// 签名后种下的 Cookie 通不过校验会被替换。密钥按用途派生，与 Session、验证码等其他用途的密钥互不相同
+func DefaultKey() ([]byte, error) {
+	cfg, err := session.LoadConfig("")
+	if err != nil {
+		return nil, err
+	}
+	keyring, err := session.LoadKeyring(cfg.Keyring)
+	if err != nil {
+		return nil, err
+	}
+	return keyring.SigningKey(session.PurposeCSRF), nil
+}
+
+// Middleware 为每个请求准备令牌（存入 c.Set(ContextKey, ...)），并校验非安全方法提交的令牌
+func Middleware(opts Options) gin.HandlerFunc {
+	if opts.Path == "" {
+		opts.Path = "/"
+	}
+	return func(c *gin.Context) {
+		token := opts.current(c)
+		c.Set(ContextKey, token)
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			c.Next()
+			return
+		}
+		submitted := c.GetHeader(HeaderName)
+		if submitted == "" {
+			submitted = c.PostForm(FieldName)
+		}
+		if submitted == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "CSRF令牌缺失", "reason": "csrf_missing"})
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "CSRF令牌无效", "reason": "csrf_invalid"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Renew 生成新的令牌保存到 storage 并设为当前请求的令牌，返回新令牌；登录成功后调用，登录前的令牌随之作废。
+// 使用 session.Store 时改用 RenewSession 配合 Login 的 init 钩子，省去一次保存
+func Renew(c *gin.Context, storage Storage) (string, error) {
+	token := newToken()
+	if err := storage.Save(c, token); err != nil {
+		return "", err
+	}
+	c.Set(ContextKey, token)
+	return token, nil
+}
+
+// current 取出当前令牌：Storage 中有令牌时使用它，否则使用双重提交 Cookie，Cookie 没有（或签名不对）时生成一个新的
+func (o Options) current(c *gin.Context) string {
+	if o.Storage != nil {
+		// 读取失败（如存储不可用）时同样退回双重提交 Cookie，签名令牌的安全性不依赖 Session
+		if token, err := o.Storage.Load(c); err == nil && token != "" {
+			return token
+		}
+	}
+	if token, err := c.Cookie(CookieName); err == nil && o.valid(token) {
+		return token
+	}
+	token := newToken()
+	if len(o.Key) > 0 {
+		token += "." + o.sign(token)
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(CookieName, token, o.MaxAge, o.Path, o.Domain, o.Secure, false)
+	return token
+}
+
+func (o Options) valid(token string) bool {
+	if token == "" {
+		return false
+	}
+	if len(o.Key) == 0 {
+		return true
+	}
+	raw, sig, ok := strings.Cut(token, ".")
+	return ok && hmac.Equal([]byte(sig), []byte(o.sign(raw)))
+}
+
+func (o Options) sign(raw string) string {
+	m := hmac.New(sha256.New, o.Key)
+	m.Write([]byte(raw))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+func newToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Token 返回中间件为当前请求准备的令牌，交给前端放进请求头
+func Token(c *gin.Context) string {
+	return c.GetString(ContextKey)
+}
+
+// TemplateField 返回带令牌的隐藏表单字段，模板中写 {{ .csrfField }}
+func TemplateField(c *gin.Context) template.HTML {
+	return template.HTML(`<input type="hidden" name="` + FieldName + `" value="` + template.HTMLEscapeString(Token(c)) + `">`)
+}
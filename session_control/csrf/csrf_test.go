@@ -0,0 +1,178 @@
+package csrf
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+// memoryStorage 测试用的 Storage，记录 Save 次数
+type memoryStorage struct {
+	token string
+	saves int
+}
+
+func (s *memoryStorage) Load(*gin.Context) (string, error) { return s.token, nil }
+
+func (s *memoryStorage) Save(_ *gin.Context, token string) error {
+	s.token = token
+	s.saves++
+	return nil
+}
+
+func newRouter(opts Options) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware(opts))
+	r.GET("/csrf", func(c *gin.Context) { c.String(http.StatusOK, Token(c)) })
+	r.POST("/submit", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return r
+}
+
+// issue 用 GET /csrf 取令牌，返回令牌和下发的 Cookie（没有下发时为 nil）
+func issue(t *testing.T, r *gin.Engine) (string, *http.Cookie) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/csrf", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /csrf = %d", w.Code)
+	}
+	for _, ck := range w.Result().Cookies() {
+		if ck.Name == CookieName {
+			return w.Body.String(), ck
+		}
+	}
+	return w.Body.String(), nil
+}
+
+// reason 403 响应中的 reason，其他状态码返回空字符串
+func reason(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	if w.Code != http.StatusForbidden {
+		return ""
+	}
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	return body.Reason
+}
+
+func TestMiddlewareDoubleSubmit(t *testing.T) {
+	r := newRouter(Options{Key: testKey})
+	token, cookie := issue(t, r)
+	if cookie == nil || cookie.Value != token || cookie.HttpOnly {
+		t.Fatalf("GET /csrf cookie = %+v, want readable cookie with token %q", cookie, token)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		cookie string
+		header string
+		form   string
+		want   int
+		reason string
+	}{
+		{"header", token, token, "", http.StatusOK, ""},
+		{"form field", token, "", token, http.StatusOK, ""},
+		{"missing", token, "", "", http.StatusForbidden, "csrf_missing"},
+		{"invalid", token, "wrong", "", http.StatusForbidden, "csrf_invalid"},
+		{"no cookie", "", token, "", http.StatusForbidden, "csrf_invalid"},
+		// 子域名写入的未签名 Cookie 通不过签名校验，即使请求头带了相同的值
+		{"forged unsigned cookie", "forged", "forged", "", http.StatusForbidden, "csrf_invalid"},
+	} {
+		var req *http.Request
+		if tt.form != "" {
+			req = httptest.NewRequest(http.MethodPost, "/submit", strings.NewReader(url.Values{FieldName: {tt.form}}.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		} else {
+			req = httptest.NewRequest(http.MethodPost, "/submit", nil)
+		}
+		if tt.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: CookieName, Value: tt.cookie})
+		}
+		if tt.header != "" {
+			req.Header.Set(HeaderName, tt.header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want || reason(t, w) != tt.reason {
+			t.Fatalf("%s: POST = %d %q, want %d %q", tt.name, w.Code, reason(t, w), tt.want, tt.reason)
+		}
+	}
+}
+
+// TestMiddlewareSafeMethods 安全方法不校验令牌
+func TestMiddlewareSafeMethods(t *testing.T) {
+	r := newRouter(Options{Key: testKey})
+	r.HEAD("/csrf", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.OPTIONS("/csrf", func(c *gin.Context) { c.Status(http.StatusOK) })
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodOptions} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, "/csrf", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s without token = %d, want 200", method, w.Code)
+		}
+	}
+}
+
+// TestMiddlewareStorage 同步令牌模式：匿名访客不写 Storage，退回双重提交 Cookie；Renew 之后只认 Storage 中的令牌
+func TestMiddlewareStorage(t *testing.T) {
+	storage := &memoryStorage{}
+	r := newRouter(Options{Storage: storage, Key: testKey})
+	r.POST("/login", func(c *gin.Context) {
+		token, err := Renew(c, storage)
+		if err != nil {
+			t.Error(err)
+		}
+		c.String(http.StatusOK, token)
+	})
+
+	post := func(path, token string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set(HeaderName, token)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	anonymous, cookie := issue(t, r)
+	if storage.saves != 0 {
+		t.Fatalf("anonymous GET saved the storage %d times, want 0", storage.saves)
+	}
+	if cookie == nil || cookie.Value != anonymous {
+		t.Fatalf("anonymous GET cookie = %+v, want double-submit cookie", cookie)
+	}
+
+	w := post("/login", anonymous, cookie)
+	if w.Code != http.StatusOK {
+		t.Fatalf("login with double-submit token = %d", w.Code)
+	}
+	renewed := w.Body.String()
+	if storage.saves != 1 || storage.token != renewed || renewed == anonymous {
+		t.Fatalf("Renew: saves = %d, storage = %q, response = %q", storage.saves, storage.token, renewed)
+	}
+
+	// 登录后登录前的令牌作废
+	if w = post("/submit", anonymous, cookie); reason(t, w) != "csrf_invalid" {
+		t.Fatalf("pre-login token after login = %d %q, want csrf_invalid", w.Code, reason(t, w))
+	}
+	if w = post("/submit", renewed, cookie); w.Code != http.StatusOK {
+		t.Fatalf("renewed token = %d, want 200", w.Code)
+	}
+	if token, _ := issue(t, r); token != renewed || storage.saves != 1 {
+		t.Fatalf("GET /csrf after login = %q (saves %d), want %q", token, storage.saves, renewed)
+	}
+}
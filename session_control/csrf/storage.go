@@ -0,0 +1,64 @@
+package csrf
+
+import (
+	contrib "github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/sessions"
+)
+
+// sessionKey 令牌在 Session 中的 key
+const sessionKey = "_csrf"
+
+type gorillaStorage struct {
+	store sessions.Store
+	name  string
+}
+
+// SessionStorage 把令牌保存在 gorilla/sessions 的 Session 中（如 session_control/session.Store）
+func SessionStorage(store sessions.Store, name string) Storage {
+	return gorillaStorage{store: store, name: name}
+}
+
+// RenewSession 生成新的令牌写入 gorilla/sessions 的 Session（不保存），返回新令牌；
+// 登录时配合 session.Store.Login 使用，登录前拿到的令牌（可能是攻击者种下的）在登录后失效
+func RenewSession(sess *sessions.Session) string {
+	token := newToken()
+	sess.Values[sessionKey] = token
+	return token
+}
+
+func (s gorillaStorage) Load(c *gin.Context) (string, error) {
+	sess, err := s.store.Get(c.Request, s.name)
+	if err != nil {
+		return "", err
+	}
+	token, _ := sess.Values[sessionKey].(string)
+	return token, nil
+}
+
+func (s gorillaStorage) Save(c *gin.Context, token string) error {
+	sess, err := s.store.Get(c.Request, s.name)
+	if err != nil {
+		return err
+	}
+	sess.Values[sessionKey] = token
+	return sess.Save(c.Request, c.Writer)
+}
+
+type contribStorage struct{}
+
+// ContribStorage 把令牌保存在 gin-contrib/sessions 的默认 Session 中，需要先注册其 Session 中间件
+func ContribStorage() Storage {
+	return contribStorage{}
+}
+
+func (contribStorage) Load(c *gin.Context) (string, error) {
+	token, _ := contrib.Default(c).Get(sessionKey).(string)
+	return token, nil
+}
+
+func (contribStorage) Save(c *gin.Context, token string) error {
+	sess := contrib.Default(c)
+	sess.Set(sessionKey, token)
+	return sess.Save()
+}
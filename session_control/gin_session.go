@@ -3,39 +3,90 @@ package main
 // Gin 框架本身未内置 Session 功能，需结合gorilla/sessions实现
 // 本代码基于 Gin+gorilla/sessions 实现用户登录态管理，包含登录、鉴权、退出登录三个核心接口。
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"gin_learn/gin_zap_demo/server"
+	"gin_learn/other_function/verifier"
+	"gin_learn/session_control/auth"
+	"gin_learn/session_control/csrf"
+	"gin_learn/session_control/jwt"
+	"gin_learn/session_control/kv"
+	"gin_learn/session_control/session"
+	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/sessions"
+	"go.uber.org/zap"
 )
 
 // 定义Session相关常量
 const SessionName = "user_session" // Session名称
 
-// 声明全局存储对象（需确保密钥保密，生产环境建议从配置文件读取）
-// 用 gorilla/sessions 创建 Cookie 型 Session 存储，这个store并不是 “存储 Session 数据的容器”，而是Session 的管理器，它的核心作用是：
-// 1. 把你要保存的 Session 数据（比如user_id=1001）加密 + 签名后，直接写入客户端浏览器的 Cookie 中；
-// 2. 当客户端发起后续请求时，从 Cookie 中读取加密后的 Session 数据，解密 + 验证签名（确保数据没被篡改），还原出user_id等信息。
-// “Cookie 型 Session” 就是把 Session 数据直接存在客户端 Cookie 里，服务端不保存任何 Session 数据，只靠密钥保证数据的安全性（加密防泄露、签名防篡改）。
-var store = sessions.NewCookieStore( // 是 Session 的一种实现方式
-	[]byte("your-secret-key-1"), // 签名密钥，任意设置
-	// gorilla/sessions 的 Cookie 加密功能要求加密密钥长度必须是 16/24/32 字节（对应 AES-128/AES-192/AES-256 加密），否则将导致session.Save()时抛出加密密钥错误
-	[]byte("1234567890123456"), // 修改为正好 16 个字节 (AES-128)
-	// 或者： []byte("123456789012345678901234"), // 24字节
-	// 或者： []byte("12345678901234567890123456789012"), // 32字节
-)
+// store 服务端 Session 存储，在 initStore 中按配置创建
+// 与 sessions.NewCookieStore（把加密 + 签名后的 Session 数据整个写入 Cookie）不同，这里 Cookie 中只保存签名后的 Session ID，
+// Session 数据保存在服务端的 kv 存储中（memory/file/bolt/redis，由配置选择），因此服务端可以随时让 Session 失效。
+var store *session.Store
+
+// authService 用户注册、登录和修改密码
+var authService *auth.Service
+
+// loginGuard 登录防暴力破解：失败计数与 Session 保存在同一个存储中
+var loginGuard *auth.Guard
 
-// 初始化存储配置（可选，根据需求调整）,配置了 HttpOnly、SameSite 等安全属性，Session 默认有效期 1 小时
-// init 函数会在包加载时自动调用
-func init() {
+// powChallenge 工作量证明，注册和登录时可以代替验证码
+var powChallenge *verifier.PoW
+
+// captchaSessions 保存登录验证码ID的 Cookie 型 Session 存储，密钥随 reloadKeys 更新
+var captchaSessions *verifier.CookieStore
+
+// initStore 按配置创建 Session 存储，并配置了 HttpOnly、SameSite 等安全属性
+// 签名/加密密钥从密钥环加载（见 session.LoadKeyring），密钥缺失或长度不合法时直接返回错误，拒绝启动
+func initStore(cfg session.Config) error {
+	keyring, err := session.LoadKeyring(cfg.Keyring)
+	if err != nil {
+		return err
+	}
+	backend, err := kv.Open(cfg.Store)
+	if err != nil {
+		return err
+	}
+	// 最新的密钥用于签名新的 Cookie，轮换下来的旧密钥在宽限期内仍可解码已签发的 Cookie
+	store = session.NewStore(backend, keyring.KeyPairs(session.PurposeSession, time.Now())...)
 	// 设置Cookie的HttpOnly属性（防止JS脚本访问，增强安全性）
 	store.Options.HttpOnly = true
 	// 设置Cookie的Secure属性（仅HTTPS环境下传输，生产环境建议开启）
 	// store.Options.Secure = true
 	// 设置Cookie的SameSite属性（防止CSRF攻击，可选SameSiteLaxMode/SameSiteStrictMode）
 	store.Options.SameSite = http.SameSiteLaxMode
-	// 设置Session默认有效期（单位：秒）
-	store.Options.MaxAge = 3600 // 1小时
+	// 过期策略：空闲超时 + 绝对有效期，由 store.Sliding 中间件滑动续期
+	if store.Timeouts, err = cfg.Timeouts(); err != nil {
+		return err
+	}
+	// Session默认有效期（单位：秒），同时也是服务端数据的过期时间；登录后由续期中间件按剩余的绝对有效期调整
+	store.MaxAge(int((store.Timeouts.Absolute + store.Timeouts.Idle).Seconds()))
+	return nil
+}
+
+// reloadKeys 定期重新加载密钥环，使 keyctl rotate 的结果和旧密钥的过期无需重启即可生效：
+// Session、验证码 Session 和工作量证明的密钥都从新的密钥环重新派生；新的密钥环不合法时保留当前密钥
+func reloadKeys(path string, interval time.Duration) {
+	for range time.Tick(interval) {
+		keyring, err := session.LoadKeyring(path)
+		if err != nil {
+			log.Printf("reload session keyring failed, keep current keys: %v", err)
+			continue
+		}
+		now := time.Now()
+		store.SetKeyPairs(keyring.KeyPairs(session.PurposeSession, now)...)
+		captchaSessions.SetKeyPairs(keyring.KeyPairs(session.PurposeCaptcha, now)...)
+		powChallenge.SetKey(keyring.SigningKey(session.PurposePoW))
+	}
 }
 
 // GetSession 获取当前请求的Session对象
@@ -74,43 +125,151 @@ func DeleteSession(c *gin.Context) error {
 	return session.Save(c.Request, c.Writer)
 }
 
-// 模拟用户登录，登录成功后存储用户ID到Session
+// loginRequest 登录请求（Session 登录和 token 登录共用）
+type loginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+	Captcha  string `json:"captcha"` // 连续失败多次后必填，验证码图片见 GET /captcha；也可以填工作量证明 "<challenge>:<solution>"
+}
+
+// authenticate 绑定登录参数并校验用户名密码，失败时写入错误响应并返回 false
+func authenticate(c *gin.Context) (auth.User, bool) {
+	var req loginRequest
+	// 绑定并验证请求参数
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "参数错误"})
+		return auth.User{}, false
+	}
+
+	// 校验用户名密码（密码以 argon2id 哈希保存在用户存储中，比较使用常量时间），
+	// 按账号和 IP 统计失败次数：达到阈值后要求验证码，继续失败则临时锁定
+	user, err := loginGuard.Authenticate(c.Request.Context(), authService, req.Username, req.Password, c.ClientIP(),
+		func() bool { return powChallenge.VerifyOrCaptcha(c, req.Captcha) })
+	var locked *auth.LockedError
+	switch {
+	case errors.As(err, &locked):
+		c.Header("Retry-After", strconv.Itoa(int(locked.RetryAfter.Seconds())+1))
+		c.JSON(429, gin.H{"error": "登录失败次数过多，请稍后再试", "retry_after": int(locked.RetryAfter.Seconds()) + 1})
+		return user, false
+	case errors.Is(err, auth.ErrCaptchaRequired):
+		c.JSON(401, gin.H{"error": "请输入正确的验证码", "captcha_required": true})
+		return user, false
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		c.JSON(401, gin.H{"error": "用户名或密码错误", "captcha_required": loginGuard.CaptchaRequired(c.Request.Context(), req.Username)})
+		return user, false
+	case err != nil:
+		c.JSON(500, gin.H{"error": "登录失败"})
+		return user, false
+	}
+	return user, true
+}
+
+// 用户登录，登录成功后存储用户ID到Session
 func loginHandler(c *gin.Context) {
+	user, ok := authenticate(c)
+	if !ok {
+		return
+	}
+
+	// 存储用户ID到Session
+	session, err := GetSession(c)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Session存储失败"})
+		return
+	}
+	// 换新的 Session ID 后保存用户ID，记录登录时间（用于空闲超时和绝对有效期），并登记到用户的会话列表，用于 /sessions 查看和撤销；
+	// 同时换一个新的 CSRF 令牌，登录前的令牌作废，新令牌直接返回给前端
+	var csrfToken string
+	if err = store.Login(c.Request, c.Writer, session, "user_id", user.ID, func(s *sessions.Session) { csrfToken = csrf.RenewSession(s) }); err != nil {
+		c.JSON(500, gin.H{"error": "Session存储失败"})
+		return
+	}
+	c.JSON(200, gin.H{"message": "登录成功", "csrf_token": csrfToken})
+}
+
+// 注册新用户
+func registerHandler(c *gin.Context) {
 	var req struct {
 		Username string `json:"username" binding:"required"`
 		Password string `json:"password" binding:"required"`
 	}
-
-	// 绑定并验证请求参数
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(400, gin.H{"error": "参数错误"})
 		return
 	}
+	user, err := authService.Register(c.Request.Context(), req.Username, req.Password)
+	switch {
+	case errors.Is(err, auth.ErrUserExists):
+		c.JSON(409, gin.H{"error": "用户名已被注册"})
+	case errors.Is(err, auth.ErrInvalidUsername):
+		c.JSON(400, gin.H{"error": "用户名只能包含字母、数字、下划线、点和横线，长度3-32"})
+	case errors.Is(err, auth.ErrWeakPassword):
+		c.JSON(400, gin.H{"error": fmt.Sprintf("密码至少%d位", authService.MinPasswordLen)})
+	case err != nil:
+		c.JSON(500, gin.H{"error": "注册失败"})
+	default:
+		c.JSON(201, gin.H{"message": "注册成功", "user_id": user.ID})
+	}
+}
 
-	// 模拟验证用户名密码（实际项目需连接数据库验证）
-	if req.Username == "root" && req.Password == "admin" {
-		// 存储用户ID到Session
-		if err := SetSessionValue(c, "user_id", 1001); err != nil {
-			c.JSON(500, gin.H{"error": "Session存储失败"})
-			return
-		}
-		c.JSON(200, gin.H{"message": "登录成功"})
+// 修改密码，成功后撤销该用户所有设备上的登录（包括当前设备），需要重新登录
+func changePasswordHandler(c *gin.Context) {
+	_, userID, ok := currentUser(c)
+	if !ok {
 		return
 	}
-
-	c.JSON(401, gin.H{"error": "用户名或密码错误"})
+	var req struct {
+		OldPassword string `json:"old_password" binding:"required"`
+		NewPassword string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "参数错误"})
+		return
+	}
+	id, _ := userID.(int64)
+	err := authService.ChangePassword(c.Request.Context(), id, req.OldPassword, req.NewPassword)
+	switch {
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		c.JSON(401, gin.H{"error": "原密码错误"})
+		return
+	case errors.Is(err, auth.ErrWeakPassword):
+		c.JSON(400, gin.H{"error": fmt.Sprintf("密码至少%d位", authService.MinPasswordLen)})
+		return
+	case err != nil:
+		c.JSON(500, gin.H{"error": "修改密码失败"})
+		return
+	}
+	if _, err = revokeAll(c.Request.Context(), userID); err == nil {
+		err = DeleteSession(c)
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "密码已修改，但退出其他设备失败"})
+		return
+	}
+	c.JSON(200, gin.H{"message": "密码已修改，请重新登录"})
 }
 
-// 需登录后才能访问的接口（如用户个人中心）
-func profileHandler(c *gin.Context) {
-	// 从Session中获取用户ID
-	userID, err := GetSessionValue(c, "user_id")
+// currentUser 返回当前登录的 Session 和用户ID，未登录（包括 Session 已被撤销）时写入 403 并返回 false；
+// 已过期的 Session 在 store.Sliding 中间件中已返回 401
+func currentUser(c *gin.Context) (*sessions.Session, interface{}, bool) {
+	session, err := GetSession(c)
 	if err != nil {
 		c.JSON(500, gin.H{"error": "获取Session失败"})
-		return
+		return nil, nil, false
 	}
+	userID := session.Values["user_id"]
 	if userID == nil {
 		c.JSON(403, gin.H{"error": "请先登录"})
+		return nil, nil, false
+	}
+	return session, userID, true
+}
+
+// 需登录后才能访问的接口（如用户个人中心）
+func profileHandler(c *gin.Context) {
+	// 从Session中获取用户ID；被撤销的 Session 在服务端已没有数据，这里会被当作未登录
+	_, userID, ok := currentUser(c)
+	if !ok {
 		return
 	}
 
@@ -123,6 +282,13 @@ func profileHandler(c *gin.Context) {
 
 // 用户退出登录，删除Session
 func logoutHandler(c *gin.Context) {
+	if sess, err := GetSession(c); err == nil && sess.Values["user_id"] != nil {
+		// 从会话列表中移除；Session 可能已被其他设备撤销，忽略不存在的错误
+		if err = store.Revoke(c.Request.Context(), sess.Values["user_id"], sess.ID); err != nil && !errors.Is(err, session.ErrSessionNotFound) {
+			c.JSON(500, gin.H{"error": "退出登录失败"})
+			return
+		}
+	}
 	if err := DeleteSession(c); err != nil {
 		c.JSON(500, gin.H{"error": "退出登录失败"})
 		return
@@ -130,19 +296,272 @@ func logoutHandler(c *gin.Context) {
 	c.JSON(200, gin.H{"message": "退出登录成功"})
 }
 
+// 列出当前用户所有有效的登录会话
+func listSessionsHandler(c *gin.Context) {
+	current, userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	records, err := store.Sessions(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "获取会话列表失败"})
+		return
+	}
+	list := make([]gin.H, 0, len(records))
+	for _, rec := range records {
+		list = append(list, gin.H{
+			"id":         rec.ID,
+			"created_at": rec.CreatedAt,
+			"last_seen":  rec.LastSeen,
+			"ip":         rec.IP,
+			"user_agent": rec.UserAgent,
+			"current":    rec.ID == current.ID,
+		})
+	}
+	c.JSON(200, gin.H{"sessions": list})
+}
+
+// 撤销当前用户的某个会话（如在其他设备上的登录），该会话之后的请求立即变为未登录
+func revokeSessionHandler(c *gin.Context) {
+	_, userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	err := store.Revoke(c.Request.Context(), userID, c.Param("id"))
+	if errors.Is(err, session.ErrSessionNotFound) {
+		c.JSON(404, gin.H{"error": "会话不存在"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "撤销会话失败"})
+		return
+	}
+	c.JSON(200, gin.H{"message": "会话已撤销"})
+}
+
+// 退出所有设备上的登录
+func logoutAllHandler(c *gin.Context) {
+	_, userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	n, err := revokeAll(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "退出登录失败"})
+		return
+	}
+	if err = DeleteSession(c); err != nil {
+		c.JSON(500, gin.H{"error": "退出登录失败"})
+		return
+	}
+	c.JSON(200, gin.H{"message": "已退出所有设备", "revoked": n})
+}
+
+// revokeAll 撤销用户所有的 Session 和 refresh token，返回撤销的 Session 数量
+func revokeAll(ctx context.Context, userID any) (int, error) {
+	n, err := store.RevokeAll(ctx, userID)
+	if err != nil {
+		return n, err
+	}
+	if id, ok := userID.(int64); ok && tokenIssuer != nil {
+		err = tokenIssuer.RevokeUser(ctx, id)
+	}
+	return n, err
+}
+
+// tokenIssuer 签发和校验 JWT，未配置 JWT 密钥时为 nil，token 相关接口不注册
+var tokenIssuer *jwt.Issuer
+
+// 用户名密码换取 access token + refresh token（移动端等不方便使用 Cookie 的客户端）
+func tokenHandler(c *gin.Context) {
+	user, ok := authenticate(c)
+	if !ok {
+		return
+	}
+	pair, err := tokenIssuer.Issue(c.Request.Context(), user.ID, user.Username)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "签发token失败"})
+		return
+	}
+	c.JSON(200, pair)
+}
+
+// 用 refresh token 换一对新 token，旧 refresh token 随即作废；已作废的 refresh token 再次使用会撤销整个登录
+func refreshTokenHandler(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "参数错误"})
+		return
+	}
+	pair, err := tokenIssuer.Refresh(c.Request.Context(), req.RefreshToken)
+	switch {
+	case errors.Is(err, jwt.ErrRefreshReused):
+		c.JSON(401, gin.H{"error": "refresh token 已被使用，请重新登录", "reason": "refresh_reused"})
+	case errors.Is(err, jwt.ErrExpired):
+		c.JSON(401, gin.H{"error": "refresh token 已过期，请重新登录", "reason": "token_expired"})
+	case err != nil:
+		c.JSON(401, gin.H{"error": "refresh token 无效，请重新登录", "reason": "token_invalid"})
+	default:
+		c.JSON(200, pair)
+	}
+}
+
+// 撤销 refresh token（token 方式的退出登录），已签发的 access token 在短有效期后自然失效
+func revokeTokenHandler(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "参数错误"})
+		return
+	}
+	if err := tokenIssuer.Revoke(c.Request.Context(), req.RefreshToken); err != nil {
+		c.JSON(401, gin.H{"error": "refresh token 无效"})
+		return
+	}
+	c.JSON(200, gin.H{"message": "退出登录成功"})
+}
+
+// 需携带 access token 访问的接口，jwt 中间件已把用户名和 Claims 存入上下文
+func apiProfileHandler(c *gin.Context) {
+	username, _ := c.Get("username")
+	claims, _ := jwt.ClaimsFrom(c)
+	c.JSON(200, gin.H{
+		"message":  "个人中心",
+		"user_id":  claims.Subject,
+		"username": username,
+	})
+}
+
+// initTokenIssuer 加载 JWT 密钥；密钥文件不存在时返回 nil（不启用 token 接口），其他错误拒绝启动
+func initTokenIssuer(cfg session.JWTConfig, backend kv.Store) (*jwt.Issuer, error) {
+	keys, err := jwt.LoadKeySet(cfg.Keys)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	issuer := jwt.NewIssuer(keys, cfg.Issuer, backend)
+	if issuer.AccessTTL, issuer.RefreshTTL, err = cfg.TTLs(); err != nil {
+		return nil, err
+	}
+	return issuer, nil
+}
+
 func main() {
+	// Session 存储配置，如 go run session_control/gin_session.go -config session_control/session.json
+	// 也可用环境变量覆盖，如 SESSION_BACKEND=redis SESSION_ADDR=127.0.0.1:6380（本地可用 go run ./session_control/respd 启动 Redis 替身）
+	// 首次运行前先生成密钥环：go run ./session_control/keyctl init
+	configPath := flag.String("config", "", "session config file (json)")
+	flag.Parse()
+	cfg, err := session.LoadConfig(*configPath)
+	if err != nil {
+		panic("加载Session配置失败: " + err.Error())
+	}
+	if err = initStore(cfg); err != nil {
+		panic("创建Session存储失败: " + err.Error())
+	}
+	// 用户存储：默认 bbolt 文件 ./session_control/data/users-gin_session.db，先用 /register 注册用户；
+	// 要与 data_parse_binding 的示例共享用户，设置 AUTH_USERS_BACKEND=redis AUTH_USERS_ADDR=127.0.0.1:6380（见 auth.Open）
+	users, err := auth.Open("gin_session")
+	if err != nil {
+		panic("打开用户存储失败: " + err.Error())
+	}
+	authService = auth.NewService(users)
+	loginGuard = auth.NewGuard(store.Backend(), auth.DefaultGuardConfig)
+	// 锁定等审计日志
+	auditLogger, err := zap.NewProduction()
+	if err != nil {
+		panic("创建日志失败: " + err.Error())
+	}
+	loginGuard.Logger = auditLogger
+	// 验证码ID保存在单独的 Cookie 型 Session 中（与 other_function 的验证码示例相同），答案与 Session 保存在同一个存储中
+	verifier.UseStore(verifier.NewStore(store.Backend(), verifier.DefaultExpiration, verifier.DefaultMaxAttempts))
+	keyring, err := session.LoadKeyring(cfg.Keyring)
+	if err != nil {
+		panic("加载Session密钥失败: " + err.Error())
+	}
+	// 工作量证明：图片验证码的替代方案（无障碍、第一方客户端），challenge 用密钥环为 PoW 派生的密钥签名
+	powChallenge = verifier.NewPoW(keyring.SigningKey(session.PurposePoW), store.Backend())
+	captchaSessions = verifier.SessionStore(600, keyring.KeyPairs(session.PurposeCaptcha, time.Now())...)
+	go reloadKeys(cfg.Keyring, time.Minute)
+
+	// JWT：access/refresh token 鉴权，refresh token 状态与 Session 保存在同一个存储中
+	if tokenIssuer, err = initTokenIssuer(cfg.JWT, store.Backend()); err != nil {
+		panic("加载JWT密钥失败: " + err.Error())
+	}
+
 	// 初始化Gin引擎
 	r := gin.Default()
+	// 不信任 X-Forwarded-For，c.ClientIP() 取连接地址，防止伪造 IP 绕过按 IP 的失败计数（部署在反向代理后时改为代理地址）
+	_ = r.SetTrustedProxies(nil)
+	r.Use(verifier.Session("captcha_session", captchaSessions))
+	// Session 续期：空闲超时/绝对有效期到期返回 401（reason 区分原因），活跃用户按 renew_interval 节流续期
+	r.Use(store.Sliding(SessionName, "user_id"))
 
 	// 注册路由
 	// 最好用postman测试
-	// curl -X POST http://127.0.0.1:8080/login -H 'content-type: application/json' -d '{"username":"root","password":"admin"}'
-	r.POST("/login", loginHandler)    // 登录（存储Session）
-	r.GET("/profile", profileHandler) // 个人中心（获取Session）
-	r.POST("/logout", logoutHandler)  // 退出登录（删除Session）
+	r.GET("/captcha", verifier.CaptchaHandler(verifier.LoginCaptcha))                     // 登录验证码（ID 保存在 Session 中）
+	r.POST("/captcha", verifier.Create(verifier.RegisterCaptcha))                         // 无状态验证码（ID 由客户端保存）
+	r.GET("/captcha/:file", verifier.Media(verifier.RegisterCaptcha))                     // 无状态验证码的图片和语音：<captcha_id>.png / .wav
+	r.POST("/pow/challenge", powChallenge.ChallengeHandler(verifier.DefaultVerifyLimits)) // 工作量证明 challenge，按 IP 限流
+	r.GET("/pow/solver.js", verifier.SolverHandler)                                       // 浏览器端求解脚本
+
+	// 依赖 Session Cookie 的接口都需要 CSRF 令牌（SameSite=Lax 挡不住同站子域名和顶级导航 GET 之外的所有情况），
+	// 登录和注册也不例外：否则跨站页面可以让受害者登录到攻击者的账号（登录 CSRF）。
+	// 先 GET /csrf 取令牌，修改类请求在 X-CSRF-Token 请求头中带上。登录前令牌是签名的双重提交 Cookie（不创建 Session），
+	// 登录成功后换成保存在 Session 中的新令牌，在登录响应中返回
+	// 注册需要验证码：先 POST /captcha 取得 captcha_id 和图片，注册时一起提交；也可以用工作量证明代替验证码：
+	// POST /pow/challenge 取得 challenge，求解后以 pow_challenge / pow_solution 提交
+	// curl -c cookie.txt -b cookie.txt http://127.0.0.1:8080/csrf
+	// curl -X POST http://127.0.0.1:8080/captcha
+	// curl -c cookie.txt -b cookie.txt -X POST http://127.0.0.1:8080/register -H 'X-CSRF-Token: <csrf_token>' -H 'content-type: application/json' -d '{"username":"root","password":"admin123","captcha_id":"<captcha_id>","captcha_answer":"1234"}'
+	// curl -c cookie.txt -b cookie.txt -X POST http://127.0.0.1:8080/login -H 'X-CSRF-Token: <csrf_token>' -H 'content-type: application/json' -d '{"username":"root","password":"admin123"}'
+	// curl -b cookie.txt -X POST http://127.0.0.1:8080/logout -H 'X-CSRF-Token: <登录响应中的 csrf_token>'
+	authed := r.Group("", csrf.Middleware(csrf.Options{
+		Storage: csrf.SessionStorage(store, SessionName),
+		Key:     keyring.SigningKey(session.PurposeCSRF),
+	}))
+	{
+		authed.GET("/csrf", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"csrf_token": csrf.Token(c)}) })
+		authed.POST("/register", verifier.RequireChallenge(powChallenge, verifier.DefaultVerifyLimits), registerHandler) // 注册
+		authed.POST("/login", loginHandler)                                                                              // 登录（存储Session）
+		authed.GET("/profile", profileHandler)                                                                           // 个人中心（获取Session）
+		authed.POST("/logout", logoutHandler)                                                                            // 退出登录（删除Session）
 
-	// 启动服务，监听8080端口
-	if err := r.Run(":8080"); err != nil {
+		// 会话管理：查看所有设备上的登录、撤销某个会话、退出所有设备
+		authed.GET("/sessions", listSessionsHandler)
+		authed.DELETE("/sessions/:id", revokeSessionHandler)
+		authed.POST("/logout-all", logoutAllHandler)
+		authed.POST("/password", changePasswordHandler) // 修改密码
+	}
+
+	// token 鉴权（先生成密钥：go run ./session_control/keyctl jwt-add -alg EdDSA）
+	// curl -X POST http://127.0.0.1:8080/token -H 'content-type: application/json' -d '{"username":"root","password":"admin123"}'
+	// curl http://127.0.0.1:8080/api/profile -H 'Authorization: Bearer <access_token>'
+	if tokenIssuer != nil {
+		r.POST("/token", tokenHandler)
+		r.POST("/token/refresh", refreshTokenHandler)
+		r.POST("/token/revoke", revokeTokenHandler)
+		api := r.Group("/api", tokenIssuer.Middleware())
+		api.GET("/profile", apiProfileHandler)
+	} else {
+		log.Printf("jwt keys %s not found, token endpoints disabled", cfg.JWT.Keys)
+	}
+
+	// 启动服务，监听8080端口；Ctrl+C 后等待在途请求处理完再退出
+	opts := server.Options{
+		Addr: ":8080",
+		OnShutdown: []func(context.Context) error{
+			func(context.Context) error { return store.Close() },
+			func(context.Context) error { return users.Close() },
+			func(context.Context) error { _ = auditLogger.Sync(); return nil },
+		},
+	}
+	if err := server.Run(r, opts); err != nil {
 		panic("Gin服务启动失败: " + err.Error())
 	}
 }
@@ -0,0 +1,213 @@
+package jwt
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"gin_learn/session_control/kv"
+
+	"github.com/gorilla/securecookie"
+)
+
+// token 类型
+const (
+	TypeAccess  = "access"
+	TypeRefresh = "refresh"
+)
+
+var (
+	// ErrWrongType 用 refresh token 访问接口，或用 access token 刷新
+	ErrWrongType = errors.New("jwt: wrong token type")
+	// ErrRefreshRevoked refresh token 已被撤销、已过期或已被使用过
+	ErrRefreshRevoked = errors.New("jwt: refresh token revoked")
+	// ErrRefreshReused 已使用过的 refresh token 被再次使用，说明可能已泄露，整个轮换族都已撤销
+	ErrRefreshReused = errors.New("jwt: refresh token reuse detected")
+)
+
+// refresh token 状态在 kv 中的 key
+const (
+	refreshPrefix = "jwt_refresh:"        // jwt_refresh:<jti> -> "0"（未使用）/ "1"（已使用）
+	familyPrefix  = "jwt_refresh_family:" // jwt_refresh_family:<fam> -> 存在表示整族已撤销
+	userGenPrefix = "jwt_user_gen:"       // jwt_user_gen:<sub> -> 用户当前的撤销代数，见 RevokeUser
+)
+
+// Pair 登录或刷新后返回给客户端的一对 token
+type Pair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"` // access token 有效期（秒）
+}
+
+// Issuer 签发短期 access token 和可轮换的 refresh token：
+// 每次刷新都作废旧 refresh token 并签发新的，旧 token 再次被使用时撤销整个轮换族（RFC 6819 5.2.2.3）
+type Issuer struct {
+	Keys       *KeySet
+	Issuer     string        // iss
+	AccessTTL  time.Duration // 默认 15 分钟
+	RefreshTTL time.Duration // 默认 7 天
+
+	kv kv.Store
+	mu sync.Mutex
+}
+
+// NewIssuer 创建签发器，refresh token 状态保存在 backend 中
+func NewIssuer(keys *KeySet, issuer string, backend kv.Store) *Issuer {
+	return &Issuer{Keys: keys, Issuer: issuer, AccessTTL: 15 * time.Minute, RefreshTTL: 7 * 24 * time.Hour, kv: backend}
+}
+
+// Issue 登录成功后签发一对 token，开始一个新的轮换族
+func (is *Issuer) Issue(ctx context.Context, userID int64, username string) (Pair, error) {
+	subject := strconv.FormatInt(userID, 10)
+	gen, err := is.generation(ctx, subject)
+	if err != nil {
+		return Pair{}, err
+	}
+	return is.issue(ctx, subject, username, newJTI(), gen)
+}
+
+// Refresh 用 refresh token 换一对新 token
+func (is *Issuer) Refresh(ctx context.Context, refreshToken string) (Pair, error) {
+	claims, err := Parse(refreshToken, is.Keys, is.Issuer, time.Now())
+	if err != nil {
+		return Pair{}, err
+	}
+	if claims.Type != TypeRefresh {
+		return Pair{}, ErrWrongType
+	}
+
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	if _, err = is.kv.Get(ctx, familyPrefix+claims.Family); err == nil {
+		return Pair{}, ErrRefreshRevoked
+	} else if !errors.Is(err, kv.ErrNotFound) {
+		return Pair{}, err
+	}
+	gen, err := is.generation(ctx, claims.Subject)
+	if err != nil {
+		return Pair{}, err
+	}
+	if claims.Generation < gen {
+		return Pair{}, ErrRefreshRevoked
+	}
+	state, err := is.kv.Get(ctx, refreshPrefix+claims.ID)
+	if errors.Is(err, kv.ErrNotFound) {
+		return Pair{}, ErrRefreshRevoked
+	}
+	if err != nil {
+		return Pair{}, err
+	}
+	if string(state) == "1" {
+		// 重放：撤销整个轮换族，攻击者和合法用户手中的 refresh token 都失效
+		if err = is.revokeFamily(ctx, claims.Family); err != nil {
+			return Pair{}, err
+		}
+		return Pair{}, ErrRefreshReused
+	}
+	// 标记为已使用而不是删除，才能在重放时识别出来
+	if err = is.kv.Set(ctx, refreshPrefix+claims.ID, []byte("1"), time.Until(time.Unix(claims.ExpiresAt, 0))); err != nil {
+		return Pair{}, err
+	}
+	return is.issue(ctx, claims.Subject, claims.Username, claims.Family, claims.Generation)
+}
+
+// Revoke 撤销 refresh token 所在的轮换族（退出登录）
+func (is *Issuer) Revoke(ctx context.Context, refreshToken string) error {
+	claims, err := Parse(refreshToken, is.Keys, is.Issuer, time.Now())
+	if err != nil {
+		return err
+	}
+	if claims.Type != TypeRefresh {
+		return ErrWrongType
+	}
+	return is.revokeFamily(ctx, claims.Family)
+}
+
+// RevokeUser 撤销用户的所有 refresh token（修改密码、退出所有设备时调用）：用户的撤销代数加一，
+// 之前签发的 refresh token 都不能再刷新；已签发的 access token 不受影响，最多在 AccessTTL 后过期
+func (is *Issuer) RevokeUser(ctx context.Context, userID int64) error {
+	subject := strconv.FormatInt(userID, 10)
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	gen, err := is.generation(ctx, subject)
+	if err != nil {
+		return err
+	}
+	// 不设过期时间：代数回到 0 会让旧 refresh token 重新生效
+	return is.kv.Set(ctx, userGenPrefix+subject, []byte(strconv.FormatInt(gen+1, 10)), 0)
+}
+
+// generation 用户当前的撤销代数，从未撤销过时为 0
+func (is *Issuer) generation(ctx context.Context, subject string) (int64, error) {
+	b, err := is.kv.Get(ctx, userGenPrefix+subject)
+	if errors.Is(err, kv.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(b), 10, 64)
+}
+
+// Verify 校验 access token
+func (is *Issuer) Verify(accessToken string) (Claims, error) {
+	claims, err := Parse(accessToken, is.Keys, is.Issuer, time.Now())
+	if err != nil {
+		return claims, err
+	}
+	if claims.Type != TypeAccess {
+		return claims, ErrWrongType
+	}
+	return claims, nil
+}
+
+func (is *Issuer) issue(ctx context.Context, subject, username, family string, gen int64) (Pair, error) {
+	now := time.Now()
+	key := is.Keys.SigningKey()
+	access, err := Sign(Claims{
+		Issuer:    is.Issuer,
+		Subject:   subject,
+		Username:  username,
+		Type:      TypeAccess,
+		ID:        newJTI(),
+		IssuedAt:  now.Unix(),
+		NotBefore: now.Unix(),
+		ExpiresAt: now.Add(is.AccessTTL).Unix(),
+	}, key)
+	if err != nil {
+		return Pair{}, err
+	}
+	jti := newJTI()
+	refresh, err := Sign(Claims{
+		Issuer:     is.Issuer,
+		Subject:    subject,
+		Username:   username,
+		Type:       TypeRefresh,
+		ID:         jti,
+		Family:     family,
+		Generation: gen,
+		IssuedAt:   now.Unix(),
+		NotBefore:  now.Unix(),
+		ExpiresAt:  now.Add(is.RefreshTTL).Unix(),
+	}, key)
+	if err != nil {
+		return Pair{}, err
+	}
+	if err = is.kv.Set(ctx, refreshPrefix+jti, []byte("0"), is.RefreshTTL); err != nil {
+		return Pair{}, err
+	}
+	return Pair{AccessToken: access, RefreshToken: refresh, TokenType: "Bearer", ExpiresIn: int(is.AccessTTL.Seconds())}, nil
+}
+
+func (is *Issuer) revokeFamily(ctx context.Context, family string) error {
+	// 族内最晚签发的 refresh token 也不会晚于 RefreshTTL 之后过期
+	return is.kv.Set(ctx, familyPrefix+family, []byte("1"), is.RefreshTTL)
+}
+
+func newJTI() string {
+	return hex.EncodeToString(securecookie.GenerateRandomKey(16))
+}
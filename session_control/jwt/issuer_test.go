@@ -0,0 +1,137 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"gin_learn/session_control/kv"
+)
+
+func newTestIssuer(t *testing.T) *Issuer {
+	t.Helper()
+	raw, err := GenerateKey(HS256, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kj keyJSON
+	if err = json.Unmarshal(raw, &kj); err != nil {
+		t.Fatal(err)
+	}
+	k, err := kj.key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks, err := NewKeySet(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := kv.NewMemory(time.Hour)
+	t.Cleanup(func() { _ = backend.Close() })
+	return NewIssuer(ks, "test", backend)
+}
+
+func TestIssuerRefreshRotation(t *testing.T) {
+	ctx := context.Background()
+	is := newTestIssuer(t)
+	first, err := is.Issue(ctx, 1, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = is.Verify(first.AccessToken); err != nil {
+		t.Fatalf("Verify access token = %v", err)
+	}
+	if _, err = is.Verify(first.RefreshToken); !errors.Is(err, ErrWrongType) {
+		t.Fatalf("Verify refresh token = %v, want ErrWrongType", err)
+	}
+	if _, err = is.Refresh(ctx, first.AccessToken); !errors.Is(err, ErrWrongType) {
+		t.Fatalf("Refresh with access token = %v, want ErrWrongType", err)
+	}
+
+	second, err := is.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh = %v", err)
+	}
+	claims, err := is.Verify(second.AccessToken)
+	if err != nil || claims.Subject != "1" || claims.Username != "alice" {
+		t.Fatalf("refreshed access token claims = %+v, %v", claims, err)
+	}
+}
+
+// TestIssuerRefreshReuse 已使用过的 refresh token 再次使用时撤销整个轮换族，合法用户手中最新的 refresh token 也失效
+func TestIssuerRefreshReuse(t *testing.T) {
+	ctx := context.Background()
+	is := newTestIssuer(t)
+	first, err := is.Issue(ctx, 1, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := is.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = is.Refresh(ctx, first.RefreshToken); !errors.Is(err, ErrRefreshReused) {
+		t.Fatalf("reusing rotated refresh token = %v, want ErrRefreshReused", err)
+	}
+	if _, err = is.Refresh(ctx, second.RefreshToken); !errors.Is(err, ErrRefreshRevoked) {
+		t.Fatalf("latest refresh token after reuse = %v, want ErrRefreshRevoked", err)
+	}
+
+	// 其他登录（其他轮换族）不受影响
+	other, err := is.Issue(ctx, 1, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = is.Refresh(ctx, other.RefreshToken); err != nil {
+		t.Fatalf("refresh token of another family = %v", err)
+	}
+}
+
+func TestIssuerRevoke(t *testing.T) {
+	ctx := context.Background()
+	is := newTestIssuer(t)
+	pair, err := is.Issue(ctx, 1, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = is.Revoke(ctx, pair.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = is.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrRefreshRevoked) {
+		t.Fatalf("Refresh after Revoke = %v, want ErrRefreshRevoked", err)
+	}
+}
+
+// TestIssuerRevokeUser 修改密码、退出所有设备后，该用户之前所有登录的 refresh token 都失效，之后的登录不受影响
+func TestIssuerRevokeUser(t *testing.T) {
+	ctx := context.Background()
+	is := newTestIssuer(t)
+	a, _ := is.Issue(ctx, 1, "alice")
+	b, _ := is.Issue(ctx, 1, "alice")
+	rotated, err := is.Refresh(ctx, b.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, _ := is.Issue(ctx, 2, "bob")
+
+	if err = is.RevokeUser(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	for name, token := range map[string]string{"first login": a.RefreshToken, "rotated": rotated.RefreshToken} {
+		if _, err = is.Refresh(ctx, token); !errors.Is(err, ErrRefreshRevoked) {
+			t.Fatalf("%s refresh token after RevokeUser = %v, want ErrRefreshRevoked", name, err)
+		}
+	}
+	if _, err = is.Refresh(ctx, bob.RefreshToken); err != nil {
+		t.Fatalf("other user's refresh token = %v", err)
+	}
+	fresh, err := is.Issue(ctx, 1, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = is.Refresh(ctx, fresh.RefreshToken); err != nil {
+		t.Fatalf("refresh token issued after RevokeUser = %v", err)
+	}
+}
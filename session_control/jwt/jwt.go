@@ -0,0 +1,172 @@
+package jwt
+
+/*
+JWT（RFC 7519）签发与校验，只依赖标准库，支持 HS256 / RS256 / EdDSA（Ed25519）。
+
+token 头部带 kid，校验时按 kid 在 KeySet 中查找密钥，并要求头部的 alg 与密钥的算法一致，
+防止算法混淆攻击（如把 RS256 公钥当作 HS256 密钥，或 alg=none）。
+*/
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 支持的签名算法
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+var (
+	// ErrMalformed token 格式错误
+	ErrMalformed = errors.New("jwt: malformed token")
+	// ErrSignature 签名校验失败
+	ErrSignature = errors.New("jwt: invalid signature")
+	// ErrUnknownKey 找不到 kid 对应的密钥，或 alg 与密钥不匹配
+	ErrUnknownKey = errors.New("jwt: unknown key")
+	// ErrExpired token 已过期
+	ErrExpired = errors.New("jwt: token expired")
+	// ErrNotYetValid token 尚未生效
+	ErrNotYetValid = errors.New("jwt: token not valid yet")
+	// ErrIssuer 签发者不匹配
+	ErrIssuer = errors.New("jwt: invalid issuer")
+)
+
+// Leeway 校验 exp/nbf 时允许的时钟误差
+const Leeway = 30 * time.Second
+
+// Claims token 中的声明
+type Claims struct {
+	Issuer    string `json:"iss,omitempty"`
+	Subject   string `json:"sub,omitempty"` // 用户ID
+	ExpiresAt int64  `json:"exp,omitempty"`
+	NotBefore int64  `json:"nbf,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	ID        string `json:"jti,omitempty"`
+
+	Username string `json:"username,omitempty"`
+	Type     string `json:"typ,omitempty"` // access / refresh
+	Family   string `json:"fam,omitempty"` // refresh token 的轮换族，同一次登录派生的 refresh token 属于同一族
+	// Generation 签发 refresh token 时用户的撤销代数，Issuer.RevokeUser 之后旧代数的 refresh token 全部失效
+	Generation int64 `json:"gen,omitempty"`
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+// Sign 用 key 签发 token
+func Sign(claims Claims, key *Key) (string, error) {
+	if !key.canSign() {
+		return "", fmt.Errorf("jwt: key %q cannot sign", key.ID)
+	}
+	h, err := json.Marshal(header{Alg: key.Alg, Typ: "JWT", Kid: key.ID})
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	input := b64(h) + "." + b64(c)
+	sig, err := key.sign([]byte(input))
+	if err != nil {
+		return "", err
+	}
+	return input + "." + b64(sig), nil
+}
+
+// Parse 校验签名和有效期并返回声明；issuer 非空时同时校验 iss
+func Parse(token string, keys *KeySet, issuer string, now time.Time) (Claims, error) {
+	var claims Claims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, ErrMalformed
+	}
+	hb, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return claims, ErrMalformed
+	}
+	var h header
+	if err = json.Unmarshal(hb, &h); err != nil {
+		return claims, ErrMalformed
+	}
+	key := keys.Lookup(h.Kid)
+	if key == nil || key.Alg != h.Alg {
+		return claims, ErrUnknownKey
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims, ErrMalformed
+	}
+	if !key.verify([]byte(parts[0]+"."+parts[1]), sig) {
+		return claims, ErrSignature
+	}
+	cb, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return claims, ErrMalformed
+	}
+	if err = json.Unmarshal(cb, &claims); err != nil {
+		return claims, ErrMalformed
+	}
+	if claims.ExpiresAt != 0 && now.After(time.Unix(claims.ExpiresAt, 0).Add(Leeway)) {
+		return claims, ErrExpired
+	}
+	if claims.NotBefore != 0 && now.Add(Leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return claims, ErrNotYetValid
+	}
+	if issuer != "" && claims.Issuer != issuer {
+		return claims, ErrIssuer
+	}
+	return claims, nil
+}
+
+func (k *Key) sign(input []byte) ([]byte, error) {
+	switch k.Alg {
+	case HS256:
+		m := hmac.New(sha256.New, k.secret)
+		m.Write(input)
+		return m.Sum(nil), nil
+	case RS256:
+		sum := sha256.Sum256(input)
+		return rsa.SignPKCS1v15(rand.Reader, k.private.(*rsa.PrivateKey), crypto.SHA256, sum[:])
+	case EdDSA:
+		return ed25519.Sign(k.private.(ed25519.PrivateKey), input), nil
+	default:
+		return nil, fmt.Errorf("jwt: unsupported alg %q", k.Alg)
+	}
+}
+
+func (k *Key) verify(input, sig []byte) bool {
+	switch k.Alg {
+	case HS256:
+		m := hmac.New(sha256.New, k.secret)
+		m.Write(input)
+		return hmac.Equal(sig, m.Sum(nil))
+	case RS256:
+		sum := sha256.Sum256(input)
+		return rsa.VerifyPKCS1v15(k.public.(*rsa.PublicKey), crypto.SHA256, sum[:], sig) == nil
+	case EdDSA:
+		return ed25519.Verify(k.public.(ed25519.PublicKey), input, sig)
+	default:
+		return false
+	}
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
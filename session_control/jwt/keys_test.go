@@ -0,0 +1,31 @@
+package jwt
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestAddKeySameSecond 同一秒内连续轮换，kid 不能重复，否则密钥文件无法加载
+func TestAddKeySameSecond(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwt_keys.json")
+	now := time.Now()
+	first, err := AddKey(path, EdDSA, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := AddKey(path, EdDSA, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Fatalf("AddKey returned the same kid %q twice", first)
+	}
+	ks, err := LoadKeySet(path)
+	if err != nil {
+		t.Fatalf("LoadKeySet = %v", err)
+	}
+	if got := ks.SigningKey().ID; got != second {
+		t.Fatalf("signing kid = %q, want newest %q", got, second)
+	}
+}
@@ -0,0 +1,49 @@
+package jwt
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClaimsKey 中间件把 Claims 存入 gin.Context 时使用的 key；用户名另外存入 "username"，与 Cookie 鉴权中间件一致
+const ClaimsKey = "claims"
+
+// Middleware 校验 Authorization: Bearer <access token>，通过后 c.Set("username", ...) 和 c.Set(ClaimsKey, claims)；
+// 失败返回 401，过期时 reason 为 token_expired，便于客户端用 refresh token 刷新
+func (is *Issuer) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := c.GetHeader("Authorization")
+		token, ok := strings.CutPrefix(auth, "Bearer ")
+		if !ok || token == "" {
+			c.Header("WWW-Authenticate", `Bearer`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "reason": "token_missing"})
+			return
+		}
+		claims, err := is.Verify(token)
+		if err != nil {
+			reason := "token_invalid"
+			if errors.Is(err, ErrExpired) {
+				reason = "token_expired"
+			}
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized", "reason": reason})
+			return
+		}
+		c.Set("username", claims.Username)
+		c.Set(ClaimsKey, claims)
+		c.Next()
+	}
+}
+
+// ClaimsFrom 取出 Middleware 存入的 Claims
+func ClaimsFrom(c *gin.Context) (Claims, bool) {
+	v, ok := c.Get(ClaimsKey)
+	if !ok {
+		return Claims{}, false
+	}
+	claims, ok := v.(Claims)
+	return claims, ok
+}
@@ -0,0 +1,103 @@
+package main
+
+/*
+Session 密钥环管理工具。
+
+	go run ./session_control/keyctl init   -keyring ./session_control/data/keyring.json
+	go run ./session_control/keyctl rotate -keyring ./session_control/data/keyring.json -grace 24h
+	go run ./session_control/keyctl list   -keyring ./session_control/data/keyring.json
+
+rotate 生成新密钥用于签名新的 Cookie，原密钥在宽限期内仍可解码旧 Cookie，过期后在下次 rotate 时删除。
+运行中的服务会定期重新加载密钥环，无需重启。
+
+JWT 签名密钥（alg 可选 EdDSA / RS256 / HS256），新密钥放在最前面用于签发，旧密钥仍可按 kid 校验：
+
+	go run ./session_control/keyctl jwt-add -alg EdDSA -keys ./session_control/data/jwt_keys.json
+*/
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"gin_learn/session_control/jwt"
+	"gin_learn/session_control/session"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: keyctl init|rotate|list [-keyring file] [-grace 24h]\n       keyctl jwt-add [-alg EdDSA|RS256|HS256] [-keys file]")
+		os.Exit(2)
+	}
+	cmd := os.Args[1]
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	path := fs.String("keyring", "./session_control/data/keyring.json", "keyring file")
+	grace := fs.Duration("grace", 24*time.Hour, "how long retired keys keep decoding old cookies")
+	alg := fs.String("alg", jwt.EdDSA, "jwt signing algorithm: EdDSA, RS256 or HS256")
+	jwtKeys := fs.String("keys", "./session_control/data/jwt_keys.json", "jwt key file")
+	_ = fs.Parse(os.Args[2:])
+
+	if err := run(cmd, *path, *grace, *alg, *jwtKeys); err != nil {
+		fmt.Fprintln(os.Stderr, "keyctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(cmd, path string, grace time.Duration, alg, jwtKeys string) error {
+	now := time.Now()
+	switch cmd {
+	case "jwt-add":
+		kid, err := jwt.AddKey(jwtKeys, alg, now)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("added %s key %s to %s\n", alg, kid, jwtKeys)
+	case "init":
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s already exists, use rotate", path)
+		}
+		kr := &session.Keyring{Keys: []session.Key{session.NewKey(now)}}
+		if err := kr.Save(path); err != nil {
+			return err
+		}
+		fmt.Printf("created %s with key %s\n", path, kr.Keys[0].ID)
+	case "rotate":
+		kr, err := readKeyring(path)
+		if err != nil {
+			return err
+		}
+		k := kr.Rotate(now, grace)
+		if err = kr.Save(path); err != nil {
+			return err
+		}
+		fmt.Printf("new key %s, previous keys expire after %s\n", k.ID, grace)
+	case "list":
+		kr, err := readKeyring(path)
+		if err != nil {
+			return err
+		}
+		for i, k := range kr.Keys {
+			state := "active"
+			switch {
+			case i > 0 && now.Before(k.ExpiresAt):
+				state = "retiring until " + k.ExpiresAt.Format(time.RFC3339)
+			case i > 0:
+				state = "expired"
+			}
+			fmt.Printf("%s\tcreated %s\t%s\n", k.ID, k.CreatedAt.Format(time.RFC3339), state)
+		}
+	default:
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+	return nil
+}
+
+// readKeyring 只读取文件，不受 SESSION_KEYS 环境变量影响
+func readKeyring(path string) (*session.Keyring, error) {
+	if os.Getenv(session.EnvKeys) != "" {
+		return nil, errors.New(session.EnvKeys + " is set; keys from the environment are managed outside keyctl")
+	}
+	return session.LoadKeyring(path)
+}
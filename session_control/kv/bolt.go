@@ -0,0 +1,150 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var boltBucket = []byte("kv")
+
+// Bolt bbolt 嵌入式数据库存储
+type Bolt struct {
+	db   *bolt.DB
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewBolt 打开（或创建）bbolt 数据库文件，并启动间隔为 interval 的过期清理协程
+func NewBolt(path string, interval time.Duration) (*Bolt, error) {
+	if path == "" {
+		path = "./sessions.db"
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	b := &Bolt{db: db, stop: make(chan struct{})}
+	go janitor(interval, b.stop, b.sweep)
+	return b, nil
+}
+
+func (b *Bolt) Get(_ context.Context, key string) ([]byte, error) {
+	var value []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(boltBucket).Get([]byte(key))
+		if v == nil {
+			return ErrNotFound
+		}
+		var err error
+		value, err = decodeEntry(v) // decodeEntry 会复制数据，事务结束后 v 不再有效
+		return err
+	})
+	return value, err
+}
+
+func (b *Bolt) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put([]byte(key), encodeEntry(value, ttl))
+	})
+}
+
+func (b *Bolt) Delete(_ context.Context, key string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Delete([]byte(key))
+	})
+}
+
+func (b *Bolt) Take(_ context.Context, key string) ([]byte, error) {
+	var value []byte
+	found := false
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bk := tx.Bucket(boltBucket)
+		v := bk.Get([]byte(key))
+		if v == nil {
+			return nil
+		}
+		var err error
+		// 已过期的数据同样删除，但不返回给调用方
+		value, err = decodeEntry(v)
+		switch {
+		case err == nil:
+			found = true
+		case !errors.Is(err, ErrNotFound):
+			return err
+		}
+		return bk.Delete([]byte(key))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrNotFound
+	}
+	return value, nil
+}
+
+func (b *Bolt) SetNX(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	set := false
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bk := tx.Bucket(boltBucket)
+		if v := bk.Get([]byte(key)); v != nil {
+			if _, err := decodeEntry(v); !errors.Is(err, ErrNotFound) {
+				return err
+			}
+		}
+		set = true
+		return bk.Put([]byte(key), encodeEntry(value, ttl))
+	})
+	return set && err == nil, err
+}
+
+func (b *Bolt) Incr(_ context.Context, key string) (int64, error) {
+	var n int64
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bk := tx.Bucket(boltBucket)
+		next, v, err := incrEntry(bk.Get([]byte(key)))
+		if err != nil {
+			return err
+		}
+		n = v
+		return bk.Put([]byte(key), next)
+	})
+	return n, err
+}
+
+func (b *Bolt) Close() error {
+	b.once.Do(func() { close(b.stop) })
+	return b.db.Close()
+}
+
+// sweep 删除所有已过期的数据；先收集再删除，避免在遍历游标时修改 bucket
+func (b *Bolt) sweep() {
+	_ = b.db.Update(func(tx *bolt.Tx) error {
+		bk := tx.Bucket(boltBucket)
+		var keys [][]byte
+		_ = bk.ForEach(func(k, v []byte) error {
+			if _, err := decodeEntry(v); errors.Is(err, ErrNotFound) {
+				keys = append(keys, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		for _, k := range keys {
+			if err := bk.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,193 @@
+package kv
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gorilla/securecookie"
+)
+
+// File 文件存储，每个 key 对应数据目录下的一个文件，文件名为 key 的十六进制编码
+type File struct {
+	dir  string
+	stop chan struct{}
+	once sync.Once
+	// mu 写操作（Set/Delete/Take）持读锁，sweep 检查并删除过期文件时持写锁，
+	// 避免 sweep 读到过期内容后删掉并发 Set 刚写入的新文件；SetNX/Incr 先读后写，同样持写锁
+	mu sync.RWMutex
+}
+
+// NewFile 创建文件存储，目录不存在时自动创建，并启动间隔为 interval 的过期清理协程
+func NewFile(dir string, interval time.Duration) (*File, error) {
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "gin_learn_sessions")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	f := &File{dir: dir, stop: make(chan struct{})}
+	go janitor(interval, f.stop, f.sweep)
+	return f, nil
+}
+
+func (f *File) path(key string) string {
+	return filepath.Join(f.dir, hex.EncodeToString([]byte(key)))
+}
+
+func (f *File) Get(_ context.Context, key string) ([]byte, error) {
+	b, err := os.ReadFile(f.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeEntry(b)
+}
+
+// Set 先写临时文件再重命名，保证读到的总是完整内容
+func (f *File) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	tmp, err := f.writeTemp(encodeEntry(value, ttl))
+	if err != nil {
+		return err
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return os.Rename(tmp, f.path(key))
+}
+
+// SetNX 持写锁检查并写入，只在本进程内是原子的，多个进程共享数据目录时请改用 redis
+func (f *File) SetNX(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	tmp, err := f.writeTemp(encodeEntry(value, ttl))
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, err := os.ReadFile(f.path(key))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return false, err
+	default:
+		if _, err = decodeEntry(b); !errors.Is(err, ErrNotFound) {
+			return false, err
+		}
+	}
+	if err = os.Rename(tmp, f.path(key)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Incr 持写锁读取、加一并写回，原子性的范围同 SetNX
+func (f *File) Incr(_ context.Context, key string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, err := os.ReadFile(f.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		b, err = nil, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	next, n, err := incrEntry(b)
+	if err != nil {
+		return 0, err
+	}
+	tmp, err := f.writeTemp(next)
+	if err != nil {
+		return 0, err
+	}
+	if err = os.Rename(tmp, f.path(key)); err != nil {
+		_ = os.Remove(tmp)
+		return 0, err
+	}
+	return n, nil
+}
+
+// writeTemp 把 entry 写入数据目录下的临时文件，返回文件路径
+func (f *File) writeTemp(entry []byte) (string, error) {
+	tmp, err := os.CreateTemp(f.dir, ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err = tmp.Write(entry); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+func (f *File) Delete(_ context.Context, key string) error {
+	f.mu.RLock()
+	err := os.Remove(f.path(key))
+	f.mu.RUnlock()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Take 先把文件重命名为唯一的临时文件（rename 是原子的，并发时只有一个能成功），再读取并删除
+func (f *File) Take(_ context.Context, key string) ([]byte, error) {
+	tmp := filepath.Join(f.dir, ".take-"+hex.EncodeToString(securecookie.GenerateRandomKey(8)))
+	f.mu.RLock()
+	err := os.Rename(f.path(key), tmp)
+	f.mu.RUnlock()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp)
+	b, err := os.ReadFile(tmp)
+	if err != nil {
+		return nil, err
+	}
+	return decodeEntry(b)
+}
+
+func (f *File) Close() error {
+	f.once.Do(func() { close(f.stop) })
+	return nil
+}
+
+// sweep 删除所有已过期的文件
+func (f *File) sweep() {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.IsDir() || e.Name()[0] == '.' {
+			continue
+		}
+		f.sweepFile(filepath.Join(f.dir, e.Name()))
+	}
+}
+
+// sweepFile 持写锁读取并删除，删除的一定是检查过的那个文件
+func (f *File) sweepFile(p string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return
+	}
+	if _, err = decodeEntry(b); errors.Is(err, ErrNotFound) {
+		_ = os.Remove(p)
+	}
+}
@@ -0,0 +1,166 @@
+package kv
+
+/*
+带过期时间的键值存储，用于把 Session 数据保存在服务端（Cookie 中只保存签名后的 Session ID）。
+
+提供四种实现，通过 Config.Backend 选择：
+  - memory：进程内存，后台 janitor 协程定期清理过期数据，重启后数据丢失
+  - file：每个 key 一个文件，适合单机部署
+  - bolt：bbolt 嵌入式数据库，单文件持久化（没有提供 SQLite 实现：常用的驱动依赖 cgo，bbolt 是纯 Go 的，场景相同）
+  - redis：Redis 协议（RESP）客户端，多副本共享；本地可用 go run ./session_control/respd 启动替身服务
+*/
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrNotFound key 不存在或已过期
+	ErrNotFound = errors.New("kv: key not found")
+	// ErrNotInteger Incr 的 key 中保存的不是十进制整数
+	ErrNotInteger = errors.New("kv: value is not an integer")
+)
+
+// Store 带过期时间的键值存储
+type Store interface {
+	// Get 读取 key，不存在或已过期时返回 ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set 写入 key，ttl<=0 表示永不过期
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete 删除 key，key 不存在时不报错
+	Delete(ctx context.Context, key string) error
+	// Take 原子地读取并删除 key，并发调用时只有一个能取到；不存在或已过期时返回 ErrNotFound
+	Take(ctx context.Context, key string) ([]byte, error)
+	// SetNX 仅当 key 不存在（或已过期）时写入，返回是否写入；并发调用时只有一个能成功
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	// Incr 原子地把 key 中的十进制整数加一并返回新值，key 不存在时从 0 开始，保留原有的过期时间
+	Incr(ctx context.Context, key string) (int64, error)
+	// Close 释放连接、停止后台协程
+	Close() error
+}
+
+// Config 存储配置
+type Config struct {
+	Backend  string `json:"backend"`  // memory / file / bolt / redis，默认 memory
+	Dir      string `json:"dir"`      // file：数据目录
+	Path     string `json:"path"`     // bolt：数据库文件
+	Addr     string `json:"addr"`     // redis：地址，如 127.0.0.1:6379
+	Password string `json:"password"` // redis：密码
+	DB       int    `json:"db"`       // redis：库编号
+	// JanitorInterval 清理过期数据的间隔，如 "1m"；redis 由服务端负责过期，不使用该项
+	JanitorInterval string `json:"janitor_interval"`
+}
+
+// DefaultJanitorInterval 默认的过期数据清理间隔
+const DefaultJanitorInterval = time.Minute
+
+// Open 按配置创建存储
+func Open(cfg Config) (Store, error) {
+	interval := DefaultJanitorInterval
+	if cfg.JanitorInterval != "" {
+		d, err := time.ParseDuration(cfg.JanitorInterval)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("kv: invalid janitor_interval %q", cfg.JanitorInterval)
+		}
+		interval = d
+	}
+	switch cfg.Backend {
+	case "", "memory":
+		return NewMemory(interval), nil
+	case "file":
+		return NewFile(cfg.Dir, interval)
+	case "bolt":
+		return NewBolt(cfg.Path, interval)
+	case "redis":
+		return NewRedis(cfg.Addr, cfg.Password, cfg.DB), nil
+	default:
+		return nil, fmt.Errorf("kv: unknown backend %q", cfg.Backend)
+	}
+}
+
+// expiresAt ttl 对应的过期时间，0 表示永不过期
+func expiresAt(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return time.Now().Add(ttl).UnixNano()
+}
+
+func expired(at int64) bool {
+	return at != 0 && time.Now().UnixNano() >= at
+}
+
+// encodeEntry file/bolt 的存储格式：8字节过期时间 + 数据
+func encodeEntry(value []byte, ttl time.Duration) []byte {
+	b := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(b, uint64(expiresAt(ttl)))
+	copy(b[8:], value)
+	return b
+}
+
+// decodeEntry 解析存储格式，已过期时返回 ErrNotFound
+func decodeEntry(b []byte) ([]byte, error) {
+	if len(b) < 8 {
+		return nil, fmt.Errorf("kv: corrupted entry")
+	}
+	if expired(int64(binary.BigEndian.Uint64(b))) {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), b[8:]...), nil
+}
+
+// incr 把十进制整数 value 加一，value 为空时视为 0
+func incr(value []byte) (int64, error) {
+	if len(value) == 0 {
+		return 1, nil
+	}
+	n, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil || n == math.MaxInt64 {
+		return 0, ErrNotInteger
+	}
+	return n + 1, nil
+}
+
+// incrEntry 对 file/bolt 存储格式的 entry 执行 incr，保留原过期时间；entry 为 nil 或已过期时从 0 开始
+func incrEntry(entry []byte) ([]byte, int64, error) {
+	var value []byte
+	if entry != nil {
+		var err error
+		value, err = decodeEntry(entry)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			entry = nil
+		case err != nil:
+			return nil, 0, err
+		}
+	}
+	n, err := incr(value)
+	if err != nil {
+		return nil, 0, err
+	}
+	next := encodeEntry(strconv.AppendInt(nil, n, 10), 0)
+	if entry != nil {
+		copy(next, entry[:8])
+	}
+	return next, n, nil
+}
+
+// janitor 定期执行清理函数，stop 关闭后退出
+func janitor(interval time.Duration, stop <-chan struct{}, sweep func()) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			sweep()
+		case <-stop:
+			return
+		}
+	}
+}
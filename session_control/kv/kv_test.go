@@ -0,0 +1,279 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// backends 每个用例使用一个全新的存储；redis 通过 ServeRESP 在本地回环端口上启动替身服务
+func backends() map[string]func(t *testing.T) Store {
+	return map[string]func(t *testing.T) Store{
+		"memory": func(t *testing.T) Store {
+			return NewMemory(time.Hour)
+		},
+		"file": func(t *testing.T) Store {
+			f, err := NewFile(t.TempDir(), time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return f
+		},
+		"bolt": func(t *testing.T) Store {
+			b, err := NewBolt(filepath.Join(t.TempDir(), "kv.db"), time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return b
+		},
+		"redis": func(t *testing.T) Store {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			backing := NewMemory(time.Hour)
+			go func() { _ = ServeRESP(ln, backing) }()
+			t.Cleanup(func() {
+				_ = ln.Close()
+				_ = backing.Close()
+			})
+			return NewRedis(ln.Addr().String(), "", 0)
+		},
+	}
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		run  func(t *testing.T, s Store)
+	}{
+		{"get missing", func(t *testing.T, s Store) {
+			if _, err := s.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get = %v, want ErrNotFound", err)
+			}
+		}},
+		{"set and get", func(t *testing.T, s Store) {
+			if err := s.Set(ctx, "k", []byte("v1"), 0); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Set(ctx, "k", []byte("v2"), time.Hour); err != nil {
+				t.Fatal(err)
+			}
+			got, err := s.Get(ctx, "k")
+			if err != nil || string(got) != "v2" {
+				t.Fatalf("Get = %q, %v, want v2", got, err)
+			}
+		}},
+		{"binary value", func(t *testing.T, s Store) {
+			v := []byte{0, 1, '\r', '\n', 0xff}
+			if err := s.Set(ctx, "bin", v, 0); err != nil {
+				t.Fatal(err)
+			}
+			got, err := s.Get(ctx, "bin")
+			if err != nil || string(got) != string(v) {
+				t.Fatalf("Get = %v, %v, want %v", got, err, v)
+			}
+		}},
+		{"ttl expires", func(t *testing.T, s Store) {
+			if err := s.Set(ctx, "short", []byte("v"), 50*time.Millisecond); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Get(ctx, "short"); err != nil {
+				t.Fatalf("Get before expiry = %v", err)
+			}
+			time.Sleep(100 * time.Millisecond)
+			if _, err := s.Get(ctx, "short"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get after expiry = %v, want ErrNotFound", err)
+			}
+			if _, err := s.Take(ctx, "short"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Take after expiry = %v, want ErrNotFound", err)
+			}
+		}},
+		{"delete", func(t *testing.T, s Store) {
+			if err := s.Set(ctx, "k", []byte("v"), 0); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Delete(ctx, "k"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Get(ctx, "k"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get after Delete = %v, want ErrNotFound", err)
+			}
+			if err := s.Delete(ctx, "k"); err != nil {
+				t.Fatalf("Delete missing key = %v, want nil", err)
+			}
+		}},
+		{"take once", func(t *testing.T, s Store) {
+			if err := s.Set(ctx, "k", []byte("v"), time.Hour); err != nil {
+				t.Fatal(err)
+			}
+			got, err := s.Take(ctx, "k")
+			if err != nil || string(got) != "v" {
+				t.Fatalf("Take = %q, %v, want v", got, err)
+			}
+			if _, err = s.Take(ctx, "k"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("second Take = %v, want ErrNotFound", err)
+			}
+			if _, err = s.Get(ctx, "k"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get after Take = %v, want ErrNotFound", err)
+			}
+		}},
+		{"concurrent take", func(t *testing.T, s Store) {
+			if err := s.Set(ctx, "k", []byte("v"), time.Hour); err != nil {
+				t.Fatal(err)
+			}
+			var wg sync.WaitGroup
+			var won atomic.Int32
+			for range 20 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, err := s.Take(ctx, "k"); err == nil {
+						won.Add(1)
+					}
+				}()
+			}
+			wg.Wait()
+			if n := won.Load(); n != 1 {
+				t.Fatalf("%d concurrent Take calls succeeded, want 1", n)
+			}
+		}},
+		{"setnx", func(t *testing.T, s Store) {
+			if ok, err := s.SetNX(ctx, "k", []byte("v1"), time.Hour); err != nil || !ok {
+				t.Fatalf("SetNX on missing key = %v, %v, want true", ok, err)
+			}
+			if ok, err := s.SetNX(ctx, "k", []byte("v2"), time.Hour); err != nil || ok {
+				t.Fatalf("SetNX on existing key = %v, %v, want false", ok, err)
+			}
+			if got, err := s.Get(ctx, "k"); err != nil || string(got) != "v1" {
+				t.Fatalf("Get = %q, %v, want v1", got, err)
+			}
+			// 已过期的 key 视为不存在
+			if err := s.Set(ctx, "short", []byte("old"), 50*time.Millisecond); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(100 * time.Millisecond)
+			if ok, err := s.SetNX(ctx, "short", []byte("new"), 0); err != nil || !ok {
+				t.Fatalf("SetNX on expired key = %v, %v, want true", ok, err)
+			}
+		}},
+		{"concurrent setnx", func(t *testing.T, s Store) {
+			var wg sync.WaitGroup
+			var won atomic.Int32
+			for range 20 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if ok, err := s.SetNX(ctx, "k", []byte("v"), time.Hour); err == nil && ok {
+						won.Add(1)
+					}
+				}()
+			}
+			wg.Wait()
+			if n := won.Load(); n != 1 {
+				t.Fatalf("%d concurrent SetNX calls succeeded, want 1", n)
+			}
+		}},
+		{"incr", func(t *testing.T, s Store) {
+			if n, err := s.Incr(ctx, "seq"); err != nil || n != 1 {
+				t.Fatalf("Incr on missing key = %d, %v, want 1", n, err)
+			}
+			if err := s.Set(ctx, "seq", []byte("41"), 50*time.Millisecond); err != nil {
+				t.Fatal(err)
+			}
+			if n, err := s.Incr(ctx, "seq"); err != nil || n != 42 {
+				t.Fatalf("Incr = %d, %v, want 42", n, err)
+			}
+			// 保留原有的过期时间
+			time.Sleep(100 * time.Millisecond)
+			if _, err := s.Get(ctx, "seq"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get after expiry = %v, want ErrNotFound", err)
+			}
+			if err := s.Set(ctx, "text", []byte("abc"), 0); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Incr(ctx, "text"); err == nil {
+				t.Fatal("Incr on non-integer value succeeded")
+			}
+		}},
+		{"concurrent incr", func(t *testing.T, s Store) {
+			var wg sync.WaitGroup
+			var seen sync.Map
+			for range 20 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					n, err := s.Incr(ctx, "seq")
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if _, dup := seen.LoadOrStore(n, true); dup {
+						t.Errorf("Incr returned %d twice", n)
+					}
+				}()
+			}
+			wg.Wait()
+			if got, err := s.Get(ctx, "seq"); err != nil || string(got) != "20" {
+				t.Fatalf("Get after 20 Incr = %q, %v, want 20", got, err)
+			}
+		}},
+	}
+	for name, open := range backends() {
+		t.Run(name, func(t *testing.T) {
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					s := open(t)
+					defer s.Close()
+					tt.run(t, s)
+				})
+			}
+		})
+	}
+}
+
+// TestFileSweepKeepsFreshValue sweep 不能删除过期后又被重新写入的 key
+func TestFileSweepKeepsFreshValue(t *testing.T) {
+	ctx := context.Background()
+	f, err := NewFile(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				f.sweep()
+			}
+		}
+	}()
+	for range 200 {
+		if err = f.Set(ctx, "k", []byte("old"), time.Nanosecond); err != nil {
+			t.Fatal(err)
+		}
+		if err = f.Set(ctx, "k", []byte("new"), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = f.Get(ctx, "k"); err != nil {
+			close(stop)
+			wg.Wait()
+			t.Fatalf("Get after fresh Set = %v, sweep removed a live entry", err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
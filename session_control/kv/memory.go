@@ -0,0 +1,105 @@
+package kv
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Memory 进程内存存储
+type Memory struct {
+	mu    sync.RWMutex
+	items map[string]memoryItem
+	stop  chan struct{}
+	once  sync.Once
+}
+
+type memoryItem struct {
+	value     []byte
+	expiresAt int64
+}
+
+// NewMemory 创建内存存储，并启动间隔为 interval 的过期清理协程
+func NewMemory(interval time.Duration) *Memory {
+	m := &Memory{items: make(map[string]memoryItem), stop: make(chan struct{})}
+	go janitor(interval, m.stop, m.sweep)
+	return m
+}
+
+func (m *Memory) Get(_ context.Context, key string) ([]byte, error) {
+	m.mu.RLock()
+	it, ok := m.items[key]
+	m.mu.RUnlock()
+	if !ok || expired(it.expiresAt) {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), it.value...), nil
+}
+
+func (m *Memory) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	m.items[key] = memoryItem{value: append([]byte(nil), value...), expiresAt: expiresAt(ttl)}
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Memory) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	delete(m.items, key)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Memory) Take(_ context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	it, ok := m.items[key]
+	delete(m.items, key)
+	m.mu.Unlock()
+	if !ok || expired(it.expiresAt) {
+		return nil, ErrNotFound
+	}
+	return it.value, nil
+}
+
+func (m *Memory) SetNX(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if it, ok := m.items[key]; ok && !expired(it.expiresAt) {
+		return false, nil
+	}
+	m.items[key] = memoryItem{value: append([]byte(nil), value...), expiresAt: expiresAt(ttl)}
+	return true, nil
+}
+
+func (m *Memory) Incr(_ context.Context, key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	it, ok := m.items[key]
+	if !ok || expired(it.expiresAt) {
+		it = memoryItem{}
+	}
+	n, err := incr(it.value)
+	if err != nil {
+		return 0, err
+	}
+	it.value = strconv.AppendInt(nil, n, 10)
+	m.items[key] = it
+	return n, nil
+}
+
+func (m *Memory) Close() error {
+	m.once.Do(func() { close(m.stop) })
+	return nil
+}
+
+// sweep 删除所有已过期的数据
+func (m *Memory) sweep() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, it := range m.items {
+		if expired(it.expiresAt) {
+			delete(m.items, k)
+		}
+	}
+}
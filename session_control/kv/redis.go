@@ -0,0 +1,245 @@
+package kv
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// redisTimeout ctx 没有截止时间时，单条命令的默认超时
+const redisTimeout = 3 * time.Second
+
+// Redis 基于 RESP 协议的最小客户端，只实现 Store 需要的命令，连接断开后在下次调用时自动重连
+type Redis struct {
+	addr     string
+	password string
+	db       int
+
+	mu   sync.Mutex
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// NewRedis 创建 Redis 存储，连接在第一次使用时建立
+func NewRedis(addr, password string, db int) *Redis {
+	if addr == "" {
+		addr = "127.0.0.1:6379"
+	}
+	return &Redis{addr: addr, password: password, db: db}
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	v, err := r.do(ctx, "GET", key)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, ErrNotFound
+	}
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("kv: unexpected redis reply %T", v)
+	}
+	return b, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	}
+	_, err := r.do(ctx, args...)
+	return err
+}
+
+func (r *Redis) Delete(ctx context.Context, key string) error {
+	_, err := r.do(ctx, "DEL", key)
+	return err
+}
+
+// Take 使用 GETDEL（Redis 6.2+）
+func (r *Redis) Take(ctx context.Context, key string) ([]byte, error) {
+	v, err := r.do(ctx, "GETDEL", key)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, ErrNotFound
+	}
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("kv: unexpected redis reply %T", v)
+	}
+	return b, nil
+}
+
+// SetNX 使用 SET key value NX [PX ttl]，多个进程共享同一个 Redis 时也是原子的
+func (r *Redis) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	args := []string{"SET", key, string(value), "NX"}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	}
+	v, err := r.do(ctx, args...)
+	if err != nil {
+		return false, err
+	}
+	return v != nil, nil
+}
+
+func (r *Redis) Incr(ctx context.Context, key string) (int64, error) {
+	v, err := r.do(ctx, "INCR", key)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := v.(int64)
+	if !ok {
+		return 0, fmt.Errorf("kv: unexpected redis reply %T", v)
+	}
+	return n, nil
+}
+
+func (r *Redis) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn, r.rd = nil, nil
+	return err
+}
+
+// do 发送一条命令并读取回复；网络错误时关闭连接，下次调用重新连接
+func (r *Redis) do(ctx context.Context, args ...string) (any, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil {
+		if err := r.connect(ctx); err != nil {
+			return nil, err
+		}
+	}
+	v, err := r.roundTrip(ctx, args...)
+	var re redisError
+	if err != nil && !errors.As(err, &re) {
+		_ = r.conn.Close()
+		r.conn, r.rd = nil, nil
+	}
+	return v, err
+}
+
+func (r *Redis) connect(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", r.addr)
+	if err != nil {
+		return err
+	}
+	r.conn, r.rd = conn, bufio.NewReader(conn)
+	if r.password != "" {
+		if _, err = r.roundTrip(ctx, "AUTH", r.password); err != nil {
+			_ = conn.Close()
+			r.conn, r.rd = nil, nil
+			return err
+		}
+	}
+	if r.db != 0 {
+		if _, err = r.roundTrip(ctx, "SELECT", strconv.Itoa(r.db)); err != nil {
+			_ = conn.Close()
+			r.conn, r.rd = nil, nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Redis) roundTrip(ctx context.Context, args ...string) (any, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisTimeout)
+	}
+	_ = r.conn.SetDeadline(deadline)
+	if _, err := r.conn.Write(encodeCommand(args...)); err != nil {
+		return nil, err
+	}
+	return readReply(r.rd)
+}
+
+// redisError 服务端返回的错误回复（-ERR ...），连接仍然可用
+type redisError string
+
+func (e redisError) Error() string {
+	return "kv: redis: " + string(e)
+}
+
+// encodeCommand 按 RESP 编码命令：*<n>\r\n$<len>\r\n<arg>\r\n...
+func encodeCommand(args ...string) []byte {
+	b := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, a := range args {
+		b = append(b, '$')
+		b = strconv.AppendInt(b, int64(len(a)), 10)
+		b = append(b, "\r\n"...)
+		b = append(b, a...)
+		b = append(b, "\r\n"...)
+	}
+	return b
+}
+
+// readReply 读取一条 RESP 回复：简单字符串返回 string，整数返回 int64，
+// 批量字符串返回 []byte（空值返回 nil），数组返回 []any，错误回复返回 redisError
+func readReply(rd *bufio.Reader) (any, error) {
+	line, err := readLine(rd)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, fmt.Errorf("kv: empty redis reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err = io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		arr := make([]any, n)
+		for i := range arr {
+			if arr[i], err = readReply(rd); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	default:
+		return nil, fmt.Errorf("kv: invalid redis reply %q", line)
+	}
+}
+
+func readLine(rd *bufio.Reader) (string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("kv: malformed redis line %q", line)
+	}
+	return line[:len(line)-2], nil
+}
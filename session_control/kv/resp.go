@@ -0,0 +1,176 @@
+package kv
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServeRESP 在 ln 上提供一个最小的 Redis 协议服务，数据保存在 s 中
+// 只支持 Redis 客户端会用到的 PING/AUTH/SELECT/GET/GETDEL/SET（PX/EX/NX）/INCR/DEL/QUIT，用作本地开发和测试时 Redis 的替身
+func ServeRESP(ln net.Listener, s Store) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go serveConn(conn, s)
+	}
+}
+
+func serveConn(conn net.Conn, s Store) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		v, err := readReply(rd)
+		if err != nil {
+			return
+		}
+		arr, ok := v.([]any)
+		if !ok || len(arr) == 0 {
+			writeError(w, "ERR protocol error")
+			_ = w.Flush()
+			return
+		}
+		args := make([]string, len(arr))
+		for i, a := range arr {
+			b, _ := a.([]byte)
+			args[i] = string(b)
+		}
+		quit := execCommand(w, s, args)
+		if err = w.Flush(); err != nil || quit {
+			return
+		}
+	}
+}
+
+// execCommand 执行一条命令并写回复，返回是否关闭连接
+func execCommand(w *bufio.Writer, s Store, args []string) bool {
+	ctx := context.Background()
+	switch cmd := strings.ToUpper(args[0]); cmd {
+	case "PING":
+		_, _ = w.WriteString("+PONG\r\n")
+	case "AUTH", "SELECT":
+		_, _ = w.WriteString("+OK\r\n")
+	case "QUIT":
+		_, _ = w.WriteString("+OK\r\n")
+		return true
+	case "GET":
+		if len(args) != 2 {
+			writeError(w, "ERR wrong number of arguments for 'get' command")
+			break
+		}
+		v, err := s.Get(ctx, args[1])
+		switch {
+		case errors.Is(err, ErrNotFound):
+			_, _ = w.WriteString("$-1\r\n")
+		case err != nil:
+			writeError(w, "ERR "+err.Error())
+		default:
+			writeBulk(w, v)
+		}
+	case "GETDEL":
+		if len(args) != 2 {
+			writeError(w, "ERR wrong number of arguments for 'getdel' command")
+			break
+		}
+		v, err := s.Take(ctx, args[1])
+		switch {
+		case errors.Is(err, ErrNotFound):
+			_, _ = w.WriteString("$-1\r\n")
+		case err != nil:
+			writeError(w, "ERR "+err.Error())
+		default:
+			writeBulk(w, v)
+		}
+	case "SET":
+		if len(args) < 3 {
+			writeError(w, "ERR syntax error")
+			break
+		}
+		var ttl time.Duration
+		nx, valid := false, true
+		for opts := args[3:]; len(opts) > 0 && valid; {
+			switch opt := strings.ToUpper(opts[0]); {
+			case opt == "NX":
+				nx, opts = true, opts[1:]
+			case (opt == "PX" || opt == "EX") && len(opts) > 1:
+				n, err := strconv.ParseInt(opts[1], 10, 64)
+				valid = err == nil && n > 0
+				if ttl = time.Duration(n) * time.Millisecond; opt == "EX" {
+					ttl = time.Duration(n) * time.Second
+				}
+				opts = opts[2:]
+			default:
+				valid = false
+			}
+		}
+		if !valid {
+			writeError(w, "ERR syntax error")
+			break
+		}
+		if !nx {
+			if err := s.Set(ctx, args[1], []byte(args[2]), ttl); err != nil {
+				writeError(w, "ERR "+err.Error())
+				break
+			}
+			_, _ = w.WriteString("+OK\r\n")
+			break
+		}
+		ok, err := s.SetNX(ctx, args[1], []byte(args[2]), ttl)
+		switch {
+		case err != nil:
+			writeError(w, "ERR "+err.Error())
+		case ok:
+			_, _ = w.WriteString("+OK\r\n")
+		default:
+			_, _ = w.WriteString("$-1\r\n")
+		}
+	case "INCR":
+		if len(args) != 2 {
+			writeError(w, "ERR wrong number of arguments for 'incr' command")
+			break
+		}
+		n, err := s.Incr(ctx, args[1])
+		switch {
+		case errors.Is(err, ErrNotInteger):
+			writeError(w, "ERR value is not an integer or out of range")
+		case err != nil:
+			writeError(w, "ERR "+err.Error())
+		default:
+			_, _ = fmt.Fprintf(w, ":%d\r\n", n)
+		}
+	case "DEL":
+		n := 0
+		for _, k := range args[1:] {
+			if _, err := s.Get(ctx, k); err == nil {
+				n++
+			}
+			_ = s.Delete(ctx, k)
+		}
+		_, _ = fmt.Fprintf(w, ":%d\r\n", n)
+	default:
+		writeError(w, fmt.Sprintf("ERR unknown command '%s'", args[0]))
+	}
+	return false
+}
+
+func writeBulk(w io.Writer, b []byte) {
+	_, _ = fmt.Fprintf(w, "$%d\r\n", len(b))
+	_, _ = w.Write(b)
+	_, _ = io.WriteString(w, "\r\n")
+}
+
+func writeError(w io.Writer, msg string) {
+	_, _ = io.WriteString(w, "-"+msg+"\r\n")
+}
@@ -0,0 +1,155 @@
+package rbac
+
+/*
+基于角色的访问控制（RBAC）：用户 -> 角色 -> 权限。
+
+权限是 "资源:操作" 形式的字符串，如 product:read、product:write；
+授予 "product:*" 表示 product 的所有操作，"*" 表示所有权限。角色可以继承其他角色的权限。
+
+策略文件（JSON）示例，见 router/rbac_policy.json：
+
+	{
+	  "roles": {
+	    "viewer": {"permissions": ["user:read", "product:read"]},
+	    "editor": {"inherits": ["viewer"], "permissions": ["product:write"]},
+	    "admin":  {"permissions": ["*"]}
+	  },
+	  "users": {"alice": ["admin"], "bob": ["editor"]},
+	  "default_roles": ["viewer"]
+	}
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// EnvPolicy 覆盖策略文件路径的环境变量
+const EnvPolicy = "RBAC_POLICY"
+
+// DefaultPolicyFile 默认策略文件
+const DefaultPolicyFile = "./router/rbac_policy.json"
+
+// Role 角色
+type Role struct {
+	Permissions []string `json:"permissions"`
+	Inherits    []string `json:"inherits,omitempty"`
+}
+
+// Policy 角色定义和用户的角色分配
+type Policy struct {
+	Roles map[string]Role     `json:"roles"`
+	Users map[string][]string `json:"users"`
+	// DefaultRoles 已登录但未在 Users 中列出的用户拥有的角色
+	DefaultRoles []string `json:"default_roles,omitempty"`
+
+	// 展开继承后每个角色的全部权限，由 Validate 计算
+	expanded map[string][]string
+}
+
+// LoadPolicy 读取策略文件；path 为空时使用 RBAC_POLICY 环境变量或 DefaultPolicyFile
+func LoadPolicy(path string) (*Policy, error) {
+	if path == "" {
+		path = os.Getenv(EnvPolicy)
+	}
+	if path == "" {
+		path = DefaultPolicyFile
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p Policy
+	if err = json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("rbac: parse %s: %w", path, err)
+	}
+	if err = p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Validate 检查引用的角色都已定义、继承关系无环，并展开每个角色的权限
+func (p *Policy) Validate() error {
+	p.expanded = make(map[string][]string, len(p.Roles))
+	for name := range p.Roles {
+		if _, err := p.expand(name, nil); err != nil {
+			return err
+		}
+	}
+	for user, roles := range p.Users {
+		for _, role := range roles {
+			if _, ok := p.Roles[role]; !ok {
+				return fmt.Errorf("rbac: user %q has undefined role %q", user, role)
+			}
+		}
+	}
+	for _, role := range p.DefaultRoles {
+		if _, ok := p.Roles[role]; !ok {
+			return fmt.Errorf("rbac: undefined default role %q", role)
+		}
+	}
+	return nil
+}
+
+func (p *Policy) expand(name string, path []string) ([]string, error) {
+	if perms, ok := p.expanded[name]; ok {
+		return perms, nil
+	}
+	if slices.Contains(path, name) {
+		return nil, fmt.Errorf("rbac: role inheritance cycle %s -> %s", strings.Join(path, " -> "), name)
+	}
+	role, ok := p.Roles[name]
+	if !ok {
+		return nil, fmt.Errorf("rbac: role %q inherits undefined role %q", path[len(path)-1], name)
+	}
+	for _, perm := range role.Permissions {
+		if perm == "" {
+			return nil, fmt.Errorf("rbac: role %q has an empty permission", name)
+		}
+	}
+	perms := slices.Clone(role.Permissions)
+	for _, parent := range role.Inherits {
+		inherited, err := p.expand(parent, append(path, name))
+		if err != nil {
+			return nil, err
+		}
+		perms = append(perms, inherited...)
+	}
+	slices.Sort(perms)
+	perms = slices.Compact(perms)
+	p.expanded[name] = perms
+	return perms, nil
+}
+
+// RolesOf 返回用户的角色，未分配时返回 DefaultRoles
+func (p *Policy) RolesOf(user string) []string {
+	if roles, ok := p.Users[user]; ok {
+		return roles
+	}
+	return p.DefaultRoles
+}
+
+// Allowed 判断 roles 是否拥有权限 perm
+func (p *Policy) Allowed(roles []string, perm string) bool {
+	for _, role := range roles {
+		for _, granted := range p.expanded[role] {
+			if Match(granted, perm) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Match 判断授予的权限 granted 是否覆盖 perm："*" 覆盖所有，"product:*" 覆盖 product 下的所有操作
+func Match(granted, perm string) bool {
+	if granted == "*" || granted == perm {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(granted, "*")
+	return ok && strings.HasSuffix(prefix, ":") && strings.HasPrefix(perm, prefix)
+}
@@ -0,0 +1,221 @@
+package rbac
+
+import (
+	"net/http"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Route 路由及其需要的权限，供 Introspect 输出
+type Route struct {
+	Method      string   `json:"method"`
+	Path        string   `json:"path"`
+	Permissions []string `json:"permissions"`
+}
+
+// Enforcer 按策略检查当前用户的权限
+type Enforcer struct {
+	Policy *Policy
+	Logger *zap.Logger // 拒绝访问时的审计日志，默认 zap.L()
+	// Subject 取出当前用户，默认读取鉴权中间件存入的 c.Get("username")
+	Subject func(c *gin.Context) (string, bool)
+
+	mu     sync.Mutex
+	routes []Route
+}
+
+// NewEnforcer 创建 Enforcer
+func NewEnforcer(policy *Policy) *Enforcer {
+	return &Enforcer{Policy: policy}
+}
+
+// RequirePermission 要求当前用户同时拥有 perms 中的所有权限：未登录返回 401，权限不足返回 403 并记录日志。
+// 需要放在鉴权中间件之后
+func (e *Enforcer) RequirePermission(perms ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := e.subject(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		roles := e.Policy.RolesOf(user)
+		for _, perm := range perms {
+			if e.Policy.Allowed(roles, perm) {
+				continue
+			}
+			e.logger().Warn("access denied",
+				zap.String("username", user),
+				zap.Strings("roles", roles),
+				zap.String("permission", perm),
+				zap.String("method", c.Request.Method),
+				zap.String("path", c.FullPath()),
+				zap.String("ip", c.ClientIP()),
+			)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "权限不足", "permission": perm})
+			return
+		}
+		c.Next()
+	}
+}
+
+func (e *Enforcer) subject(c *gin.Context) (string, bool) {
+	if e.Subject != nil {
+		return e.Subject(c)
+	}
+	user := c.GetString("username")
+	return user, user != ""
+}
+
+func (e *Enforcer) logger() *zap.Logger {
+	if e.Logger != nil {
+		return e.Logger
+	}
+	return zap.L()
+}
+
+// Routes 返回通过 Group 注册的路由及其权限，按路径排序
+func (e *Enforcer) Routes() []Route {
+	e.mu.Lock()
+	routes := slices.Clone(e.routes)
+	e.mu.Unlock()
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// Introspect 输出路由权限清单的接口：{"routes":[{"method","path","permissions"}]}
+func (e *Enforcer) Introspect(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"routes": e.Routes()})
+}
+
+func (e *Enforcer) record(method, fullPath string, perms []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.routes = append(e.routes, Route{Method: method, Path: fullPath, Permissions: append([]string{}, perms...)})
+}
+
+// Group 需要权限的路由组，组内注册的路由会记录到 Enforcer 的路由权限清单中。
+// 只提供会记录清单的注册方法（没有 Static 等），直接在路由上使用 RequirePermission 同样生效，但不会出现在清单里
+type Group struct {
+	rg    *gin.RouterGroup
+	e     *Enforcer
+	perms []string // 包括上级组的权限，只用于清单
+}
+
+// anyMethods 与 gin 的 RouterGroup.Any 注册的方法一致
+var anyMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodHead, http.MethodOptions, http.MethodDelete, http.MethodConnect, http.MethodTrace,
+}
+
+// Group 在 parent（gin.Engine 或普通路由组）下创建路由组，组内所有路由都需要 perms（可以为空，只记录清单）；
+// 嵌套的组用 (*Group).Group 创建，才能继承上级组的权限
+func (e *Enforcer) Group(parent gin.IRouter, relativePath string, perms ...string) *Group {
+	return e.newGroup(parent.Group(relativePath), nil, perms)
+}
+
+func (e *Enforcer) newGroup(rg *gin.RouterGroup, inherited, perms []string) *Group {
+	if len(perms) > 0 {
+		rg.Use(e.RequirePermission(perms...))
+	}
+	all := append(slices.Clone(inherited), perms...)
+	slices.Sort(all)
+	return &Group{rg: rg, e: e, perms: slices.Compact(all)}
+}
+
+// Group 创建子组，需要上级组的权限以及 perms
+func (g *Group) Group(relativePath string, perms ...string) *Group {
+	return g.e.newGroup(g.rg.Group(relativePath), g.perms, perms)
+}
+
+// With 为单个路由追加权限：v1.With("product:write").POST("/product", handler)
+func (g *Group) With(perms ...string) *Group {
+	return g.Group("", perms...)
+}
+
+// Use 为组内之后注册的路由添加中间件
+func (g *Group) Use(middleware ...gin.HandlerFunc) *Group {
+	g.rg.Use(middleware...)
+	return g
+}
+
+// BasePath 组的路径前缀
+func (g *Group) BasePath() string {
+	return g.rg.BasePath()
+}
+
+// Handle 注册路由并记录其权限
+func (g *Group) Handle(method, relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	g.e.record(method, joinPaths(g.BasePath(), relativePath), g.perms)
+	return g.rg.Handle(method, relativePath, handlers...)
+}
+
+// Match 为 methods 中的每个方法注册路由并记录其权限
+func (g *Group) Match(methods []string, relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	for _, method := range methods {
+		g.Handle(method, relativePath, handlers...)
+	}
+	return g.rg
+}
+
+// Any 同 Match，方法与 gin 的 Any 相同
+func (g *Group) Any(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return g.Match(anyMethods, relativePath, handlers...)
+}
+
+// GET 同 Handle(http.MethodGet, ...)
+func (g *Group) GET(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return g.Handle(http.MethodGet, relativePath, handlers...)
+}
+
+// POST 同 Handle(http.MethodPost, ...)
+func (g *Group) POST(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return g.Handle(http.MethodPost, relativePath, handlers...)
+}
+
+// PUT 同 Handle(http.MethodPut, ...)
+func (g *Group) PUT(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return g.Handle(http.MethodPut, relativePath, handlers...)
+}
+
+// PATCH 同 Handle(http.MethodPatch, ...)
+func (g *Group) PATCH(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return g.Handle(http.MethodPatch, relativePath, handlers...)
+}
+
+// DELETE 同 Handle(http.MethodDelete, ...)
+func (g *Group) DELETE(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return g.Handle(http.MethodDelete, relativePath, handlers...)
+}
+
+// HEAD 同 Handle(http.MethodHead, ...)
+func (g *Group) HEAD(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return g.Handle(http.MethodHead, relativePath, handlers...)
+}
+
+// OPTIONS 同 Handle(http.MethodOptions, ...)
+func (g *Group) OPTIONS(relativePath string, handlers ...gin.HandlerFunc) gin.IRoutes {
+	return g.Handle(http.MethodOptions, relativePath, handlers...)
+}
+
+// joinPaths 与 gin 内部拼接路由组路径的规则一致
+func joinPaths(base, relative string) string {
+	if relative == "" {
+		return base
+	}
+	joined := path.Join(base, relative)
+	if strings.HasSuffix(relative, "/") && !strings.HasSuffix(joined, "/") {
+		return joined + "/"
+	}
+	return joined
+}
@@ -0,0 +1,168 @@
+package rbac
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func TestMatch(t *testing.T) {
+	for _, tt := range []struct {
+		granted, perm string
+		want          bool
+	}{
+		{"product:read", "product:read", true},
+		{"product:read", "product:write", false},
+		{"product:*", "product:write", true},
+		{"product:*", "products:write", false},
+		{"product:*", "user:read", false},
+		{"*", "anything:at-all", true},
+		// 只有 "资源:*" 形式的通配符，"prod*" 不是
+		{"prod*", "product:read", false},
+		{"product", "product:read", false},
+	} {
+		if got := Match(tt.granted, tt.perm); got != tt.want {
+			t.Fatalf("Match(%q, %q) = %v, want %v", tt.granted, tt.perm, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		policy Policy
+		want   string // 错误信息包含的内容，空表示合法
+	}{
+		{"valid", Policy{
+			Roles: map[string]Role{"viewer": {Permissions: []string{"user:read"}}, "editor": {Inherits: []string{"viewer"}}},
+			Users: map[string][]string{"bob": {"editor"}},
+		}, ""},
+		{"self cycle", Policy{
+			Roles: map[string]Role{"a": {Inherits: []string{"a"}}},
+		}, "cycle"},
+		{"indirect cycle", Policy{
+			Roles: map[string]Role{"a": {Inherits: []string{"b"}}, "b": {Inherits: []string{"c"}}, "c": {Inherits: []string{"a"}}},
+		}, "cycle"},
+		{"undefined inherited role", Policy{
+			Roles: map[string]Role{"a": {Inherits: []string{"missing"}}},
+		}, "undefined role"},
+		{"undefined user role", Policy{
+			Roles: map[string]Role{"a": {}},
+			Users: map[string][]string{"bob": {"missing"}},
+		}, "undefined role"},
+		{"undefined default role", Policy{
+			Roles:        map[string]Role{"a": {}},
+			DefaultRoles: []string{"missing"},
+		}, "undefined default role"},
+		{"empty permission", Policy{
+			Roles: map[string]Role{"a": {Permissions: []string{""}}},
+		}, "empty permission"},
+	} {
+		err := tt.policy.Validate()
+		if tt.want == "" && err != nil {
+			t.Fatalf("%s: Validate = %v", tt.name, err)
+		}
+		if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Fatalf("%s: Validate = %v, want error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func newTestEnforcer(t *testing.T) *Enforcer {
+	t.Helper()
+	p := &Policy{
+		Roles: map[string]Role{
+			"viewer": {Permissions: []string{"product:read"}},
+			"editor": {Inherits: []string{"viewer"}, Permissions: []string{"product:write"}},
+		},
+		Users:        map[string][]string{"bob": {"editor"}},
+		DefaultRoles: []string{"viewer"},
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	e := NewEnforcer(p)
+	e.Logger = zap.NewNop()
+	return e
+}
+
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := newTestEnforcer(t)
+	r := gin.New()
+	// 测试中用 X-User 请求头模拟鉴权中间件
+	r.Use(func(c *gin.Context) {
+		if user := c.GetHeader("X-User"); user != "" {
+			c.Set("username", user)
+		}
+	})
+	g := e.Group(r, "/api")
+	g.With("product:read").GET("/product", func(c *gin.Context) { c.Status(http.StatusOK) })
+	g.With("product:write").POST("/product", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, tt := range []struct {
+		name   string
+		method string
+		user   string
+		want   int
+	}{
+		{"anonymous", http.MethodGet, "", http.StatusUnauthorized},
+		{"default role can read", http.MethodGet, "carol", http.StatusOK},
+		{"default role cannot write", http.MethodPost, "carol", http.StatusForbidden},
+		{"inherited permission", http.MethodGet, "bob", http.StatusOK},
+		{"own permission", http.MethodPost, "bob", http.StatusOK},
+	} {
+		req := httptest.NewRequest(tt.method, "/api/product", nil)
+		if tt.user != "" {
+			req.Header.Set("X-User", tt.user)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Fatalf("%s: %s /api/product = %d, want %d", tt.name, tt.method, w.Code, tt.want)
+		}
+	}
+}
+
+// TestGroupRoutes 所有注册方法都出现在路由权限清单中，子组继承上级组的权限
+func TestGroupRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	e := newTestEnforcer(t)
+	r := gin.New()
+	h := func(c *gin.Context) { c.Status(http.StatusOK) }
+	g := e.Group(r, "/api", "product:read")
+	g.Match([]string{http.MethodPut, http.MethodPatch}, "/match", h)
+	g.Any("/any", h)
+	g.HEAD("/head", h)
+	g.Group("/admin", "product:write").OPTIONS("/opts", h)
+
+	got := make(map[string]string)
+	for _, route := range e.Routes() {
+		got[route.Method+" "+route.Path] = strings.Join(route.Permissions, ",")
+	}
+	want := map[string]string{
+		"PUT /api/match":          "product:read",
+		"PATCH /api/match":        "product:read",
+		"HEAD /api/head":          "product:read",
+		"OPTIONS /api/admin/opts": "product:read,product:write",
+	}
+	for _, method := range anyMethods {
+		want[method+" /api/any"] = "product:read"
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Routes = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("Routes[%s] = %q, want %q", k, got[k], v)
+		}
+	}
+	// 注册到 gin 的路由与清单一致
+	if n := len(r.Routes()); n != len(want) {
+		t.Fatalf("gin has %d routes, want %d", n, len(want))
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+/*
+本地 Redis 替身：没有安装 Redis 时，用它来运行/测试 backend=redis 的 Session 存储。
+数据保存在内存中，只支持 GET/GETDEL/SET/DEL 等少数命令，不要用于生产环境。
+
+启动CMD： go run ./session_control/respd -addr 127.0.0.1:6380
+*/
+
+import (
+	"flag"
+	"log"
+	"net"
+
+	"gin_learn/session_control/kv"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:6380", "listen address")
+	flag.Parse()
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatalf("listen %s failed: %v", *addr, err)
+	}
+	log.Printf("RESP stand-in listening on %s", *addr)
+	if err := kv.ServeRESP(ln, kv.NewMemory(kv.DefaultJanitorInterval)); err != nil {
+		log.Fatal(err)
+	}
+}
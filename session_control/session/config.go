@@ -0,0 +1,121 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"gin_learn/session_control/kv"
+)
+
+// Config Session 配置
+type Config struct {
+	Store   kv.Config `json:"store"`
+	Keyring string    `json:"keyring"` // 密钥环文件，见 LoadKeyring；设置了 SESSION_KEYS 时忽略
+	// 过期策略，格式如 "30m"，见 Timeouts
+	IdleTimeout     string `json:"idle_timeout"`     // 默认 30m
+	AbsoluteTimeout string `json:"absolute_timeout"` // 默认 12h
+	RenewInterval   string `json:"renew_interval"`   // 默认 1m
+
+	JWT JWTConfig `json:"jwt"`
+}
+
+// JWTConfig Bearer token 配置，供不方便使用 Cookie 的客户端（如移动端）使用
+type JWTConfig struct {
+	Keys       string `json:"keys"`        // 密钥文件，用 go run ./session_control/keyctl jwt-add 生成
+	Issuer     string `json:"issuer"`      // iss，默认 gin_learn
+	AccessTTL  string `json:"access_ttl"`  // 默认 15m
+	RefreshTTL string `json:"refresh_ttl"` // 默认 168h
+}
+
+// Timeouts 解析过期策略，未设置的项使用 DefaultTimeouts
+func (c Config) Timeouts() (Timeouts, error) {
+	t := DefaultTimeouts
+	for _, item := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"idle_timeout", c.IdleTimeout, &t.Idle},
+		{"absolute_timeout", c.AbsoluteTimeout, &t.Absolute},
+		{"renew_interval", c.RenewInterval, &t.RenewInterval},
+	} {
+		if item.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(item.value)
+		if err != nil || d <= 0 {
+			return t, fmt.Errorf("session: invalid %s %q", item.name, item.value)
+		}
+		*item.dst = d
+	}
+	if t.Idle > t.Absolute {
+		return t, fmt.Errorf("session: idle_timeout %s must not exceed absolute_timeout %s", t.Idle, t.Absolute)
+	}
+	if t.RenewInterval >= t.Idle {
+		return t, fmt.Errorf("session: renew_interval %s must be less than idle_timeout %s", t.RenewInterval, t.Idle)
+	}
+	return t, nil
+}
+
+// LoadConfig 读取配置：path 为空时使用默认值，之后用 SESSION_* 环境变量覆盖，
+// 如 SESSION_BACKEND=redis SESSION_ADDR=127.0.0.1:6380
+func LoadConfig(path string) (Config, error) {
+	cfg := Config{
+		Store:   kv.Config{Backend: "memory"},
+		Keyring: "./session_control/data/keyring.json",
+		JWT:     JWTConfig{Keys: "./session_control/data/jwt_keys.json", Issuer: "gin_learn", AccessTTL: "15m", RefreshTTL: "168h"},
+	}
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, err
+		}
+		if err = json.Unmarshal(b, &cfg); err != nil {
+			return cfg, fmt.Errorf("session: parse %s: %w", path, err)
+		}
+	}
+
+	env := map[string]*string{
+		"SESSION_BACKEND":          &cfg.Store.Backend,
+		"SESSION_DIR":              &cfg.Store.Dir,
+		"SESSION_PATH":             &cfg.Store.Path,
+		"SESSION_ADDR":             &cfg.Store.Addr,
+		"SESSION_PASSWORD":         &cfg.Store.Password,
+		"SESSION_JANITOR_INTERVAL": &cfg.Store.JanitorInterval,
+		"SESSION_KEYRING":          &cfg.Keyring,
+		"SESSION_IDLE_TIMEOUT":     &cfg.IdleTimeout,
+		"SESSION_ABSOLUTE_TIMEOUT": &cfg.AbsoluteTimeout,
+		"SESSION_RENEW_INTERVAL":   &cfg.RenewInterval,
+		"JWT_KEYS":                 &cfg.JWT.Keys,
+	}
+	for name, p := range env {
+		if v, ok := os.LookupEnv(name); ok {
+			*p = v
+		}
+	}
+	if v, ok := os.LookupEnv("SESSION_DB"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return cfg, fmt.Errorf("session: invalid SESSION_DB %q", v)
+		}
+		cfg.Store.DB = n
+	}
+	if _, err := cfg.Timeouts(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// TTLs 解析 access token 和 refresh token 的有效期
+func (c JWTConfig) TTLs() (access, refresh time.Duration, err error) {
+	if access, err = time.ParseDuration(c.AccessTTL); err != nil || access <= 0 {
+		return 0, 0, fmt.Errorf("session: invalid jwt.access_ttl %q", c.AccessTTL)
+	}
+	if refresh, err = time.ParseDuration(c.RefreshTTL); err != nil || refresh <= access {
+		return 0, 0, fmt.Errorf("session: invalid jwt.refresh_ttl %q, must be longer than access_ttl", c.RefreshTTL)
+	}
+	return access, refresh, nil
+}
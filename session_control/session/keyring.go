@@ -0,0 +1,198 @@
+package session
+
+import (
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gorilla/securecookie"
+)
+
+// EnvKeys 以环境变量直接提供密钥，格式为 "id:hash:block,id:hash:block"（hash/block 为 base64，block 可为空），越靠前越新
+const EnvKeys = "SESSION_KEYS"
+
+// MinHashKeyLen 签名密钥的最小长度，securecookie 推荐 32 或 64 字节
+const MinHashKeyLen = 32
+
+// maxHashKeyLen 签名密钥的最大长度，HKDF-SHA256 最多派生 255*32 字节
+const maxHashKeyLen = 255 * sha256.Size
+
+// Key 一组 Cookie 签名/加密密钥
+type Key struct {
+	ID        string    `json:"id"`
+	Hash      []byte    `json:"hash"`            // 签名密钥（HMAC），至少 32 字节
+	Block     []byte    `json:"block,omitempty"` // 加密密钥（AES），16/24/32 字节；为空表示只签名不加密
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt 被轮换下来的密钥在这个时间之后不再用于解码；零值表示仍在使用
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+// Keyring 有序的密钥环，Keys[0] 是最新的密钥，用于签名新的 Cookie；其余密钥只用于解码旧 Cookie
+type Keyring struct {
+	Keys []Key `json:"keys"`
+}
+
+// LoadKeyring 加载密钥环：优先使用环境变量 SESSION_KEYS，否则读取 path 指向的 JSON 文件；
+// 密钥缺失或长度不合法时返回错误，避免到 session.Save() 时才失败
+func LoadKeyring(path string) (*Keyring, error) {
+	var kr Keyring
+	if v := os.Getenv(EnvKeys); v != "" {
+		keys, err := parseEnvKeys(v)
+		if err != nil {
+			return nil, err
+		}
+		kr.Keys = keys
+	} else {
+		if path == "" {
+			return nil, fmt.Errorf("session: no keys configured, set %s or create a keyring with: go run ./session_control/keyctl init", EnvKeys)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(b, &kr); err != nil {
+			return nil, fmt.Errorf("session: parse keyring %s: %w", path, err)
+		}
+	}
+	if err := kr.Validate(); err != nil {
+		return nil, err
+	}
+	return &kr, nil
+}
+
+func parseEnvKeys(v string) ([]Key, error) {
+	var keys []Key
+	for _, item := range strings.Split(v, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("session: invalid %s entry %q, want id:hash[:block]", EnvKeys, item)
+		}
+		k := Key{ID: parts[0]}
+		var err error
+		if k.Hash, err = base64.StdEncoding.DecodeString(parts[1]); err != nil {
+			return nil, fmt.Errorf("session: key %q: invalid hash key: %w", k.ID, err)
+		}
+		if len(parts) == 3 && parts[2] != "" {
+			if k.Block, err = base64.StdEncoding.DecodeString(parts[2]); err != nil {
+				return nil, fmt.Errorf("session: key %q: invalid block key: %w", k.ID, err)
+			}
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// Validate 检查密钥环：至少一个未过期的密钥、ID 不重复、密钥长度合法
+func (kr *Keyring) Validate() error {
+	if len(kr.Keys) == 0 {
+		return errors.New("session: keyring is empty")
+	}
+	if !kr.Keys[0].ExpiresAt.IsZero() {
+		return fmt.Errorf("session: newest key %q is retired", kr.Keys[0].ID)
+	}
+	seen := make(map[string]bool, len(kr.Keys))
+	for _, k := range kr.Keys {
+		if k.ID == "" {
+			return errors.New("session: key without id")
+		}
+		if seen[k.ID] {
+			return fmt.Errorf("session: duplicate key id %q", k.ID)
+		}
+		seen[k.ID] = true
+		if len(k.Hash) < MinHashKeyLen || len(k.Hash) > maxHashKeyLen {
+			return fmt.Errorf("session: key %q: hash key must be %d to %d bytes, got %d", k.ID, MinHashKeyLen, maxHashKeyLen, len(k.Hash))
+		}
+		switch len(k.Block) {
+		case 0, 16, 24, 32:
+		default:
+			return fmt.Errorf("session: key %q: block key must be 16, 24 or 32 bytes, got %d", k.ID, len(k.Block))
+		}
+	}
+	return nil
+}
+
+// 密钥用途：同一个密钥环为每种用途派生不同的密钥（HKDF-SHA256，info 为用途），
+// 一种用途签发的值（如 Cookie、PoW challenge）不能拿到另一种用途中通过校验
+const (
+	PurposeSession = "session" // 服务端 Session 的 Session ID Cookie
+	PurposeCaptcha = "captcha" // 保存验证码ID的 Cookie 型 Session
+	PurposeCookie  = "cookie"  // cookie.Codec 签名的普通 Cookie
+	PurposeCSRF    = "csrf"    // CSRF double-submit Cookie
+	PurposePoW     = "pow"     // 工作量证明 challenge 的 HMAC
+)
+
+// KeyPairs 返回 now 时刻仍然有效的密钥为 purpose 派生出的密钥，按 securecookie.CodecsFromPairs 需要的 hash, block 顺序排列
+func (kr *Keyring) KeyPairs(purpose string, now time.Time) [][]byte {
+	var pairs [][]byte
+	for _, k := range kr.Keys {
+		if !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt) {
+			continue
+		}
+		pairs = append(pairs, derive(k.Hash, purpose, "hash"), derive(k.Block, purpose, "block"))
+	}
+	return pairs
+}
+
+// SigningKey 最新的密钥为 purpose 派生出的签名密钥，用于 HMAC 等只需要单个密钥的场景
+func (kr *Keyring) SigningKey(purpose string) []byte {
+	return derive(kr.Keys[0].Hash, purpose, "hash")
+}
+
+// derive 从 secret 派生与之等长的子密钥；secret 为空（不加密）时返回 nil
+func derive(secret []byte, purpose, use string) []byte {
+	if len(secret) == 0 {
+		return nil
+	}
+	// 输出长度不超过 255*32 字节时 hkdf.Key 不会出错，Validate 之后的密钥都满足
+	key, _ := hkdf.Key(sha256.New, secret, nil, "gin_learn/"+purpose+"/"+use, len(secret))
+	return key
+}
+
+// Rotate 生成新密钥放到最前面；原来在用的密钥在 grace 之后失效，已过宽限期的密钥从密钥环中删除
+func (kr *Keyring) Rotate(now time.Time, grace time.Duration) Key {
+	kept := kr.Keys[:0]
+	for _, k := range kr.Keys {
+		if k.ExpiresAt.IsZero() {
+			k.ExpiresAt = now.Add(grace)
+		}
+		if now.Before(k.ExpiresAt) {
+			kept = append(kept, k)
+		}
+	}
+	k := NewKey(now)
+	kr.Keys = append([]Key{k}, kept...)
+	return k
+}
+
+// NewKey 生成随机的 64 字节签名密钥和 32 字节（AES-256）加密密钥
+func NewKey(now time.Time) Key {
+	return Key{
+		ID:        fmt.Sprintf("%s-%x", now.UTC().Format("20060102T150405Z"), securecookie.GenerateRandomKey(2)),
+		Hash:      securecookie.GenerateRandomKey(64),
+		Block:     securecookie.GenerateRandomKey(32),
+		CreatedAt: now,
+	}
+}
+
+// Save 写入 JSON 文件（权限 0600），先写临时文件再重命名
+func (kr *Keyring) Save(path string) error {
+	b, err := json.MarshalIndent(kr, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,48 @@
+package session
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/gorilla/securecookie"
+)
+
+// TestKeyPairsPerPurpose 不同用途派生的密钥互不相同，同一用途的结果稳定，轮换下来的旧密钥过期后不再返回
+func TestKeyPairsPerPurpose(t *testing.T) {
+	now := time.Now()
+	kr := &Keyring{Keys: []Key{NewKey(now), NewKey(now.Add(-time.Hour))}}
+	kr.Keys[1].ExpiresAt = now.Add(time.Minute)
+	if err := kr.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	session, cookie := kr.KeyPairs(PurposeSession, now), kr.KeyPairs(PurposeCookie, now)
+	if len(session) != 4 {
+		t.Fatalf("KeyPairs returned %d keys, want 4", len(session))
+	}
+	for i := range session {
+		if bytes.Equal(session[i], cookie[i]) || bytes.Equal(session[i], kr.Keys[i/2].Hash) || bytes.Equal(session[i], kr.Keys[i/2].Block) {
+			t.Fatalf("key %d is shared between purposes or equals the raw key", i)
+		}
+	}
+	if again := kr.KeyPairs(PurposeSession, now); !bytes.Equal(again[0], session[0]) || !bytes.Equal(again[1], session[1]) {
+		t.Fatal("KeyPairs is not deterministic")
+	}
+	if !bytes.Equal(kr.SigningKey(PurposeSession), session[0]) {
+		t.Fatal("SigningKey differs from the first hash key")
+	}
+	if got := kr.KeyPairs(PurposeSession, now.Add(time.Hour)); len(got) != 2 {
+		t.Fatalf("KeyPairs after expiry returned %d keys, want 2", len(got))
+	}
+
+	// 用一种用途的密钥编码的值，换一种用途无法解码
+	encoded, err := securecookie.EncodeMulti("name", "value", securecookie.CodecsFromPairs(session...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	if err = securecookie.DecodeMulti("name", encoded, &got, securecookie.CodecsFromPairs(cookie...)...); err == nil {
+		t.Fatal("value encoded for the session purpose decoded with cookie keys")
+	}
+}
@@ -0,0 +1,178 @@
+package session
+
+/*
+服务端 Session 存储：实现 gorilla/sessions 的 sessions.Store 接口。
+
+与 sessions.NewCookieStore 不同，Cookie 中只保存签名后的 Session ID，
+Session 数据保存在服务端的 kv.Store 中（memory/file/bolt/redis），因此服务端可以随时让某个 Session 失效。
+*/
+
+import (
+	"bytes"
+	"context"
+	"encoding/base32"
+	"encoding/gob"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gin_learn/session_control/kv"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// keyPrefix 存储中 Session 数据的 key 前缀
+const keyPrefix = "session:"
+
+// Store 把 Session 数据保存在 kv.Store 中的 sessions.Store 实现
+type Store struct {
+	Options  *sessions.Options // Cookie 的默认属性，MaxAge 同时作为服务端数据的过期时间
+	Timeouts Timeouts          // 空闲超时、绝对有效期和续期间隔，由 Login 和 Sliding 使用
+
+	backend kv.Store
+	mu      sync.Mutex // 保护用户会话索引的读-改-写
+
+	keyMu  sync.RWMutex
+	codecs []securecookie.Codec
+}
+
+// NewStore 创建服务端 Session 存储，keyPairs 的用法与 sessions.NewCookieStore 相同，用于签名（和加密）Cookie 中的 Session ID
+func NewStore(backend kv.Store, keyPairs ...[]byte) *Store {
+	s := &Store{
+		codecs: securecookie.CodecsFromPairs(keyPairs...),
+		Options: &sessions.Options{
+			Path:   "/",
+			MaxAge: 86400 * 30,
+		},
+		Timeouts: DefaultTimeouts,
+		backend:  backend,
+	}
+	s.MaxAge(s.Options.MaxAge)
+	return s
+}
+
+// Backend 返回底层的键值存储
+func (s *Store) Backend() kv.Store {
+	return s.backend
+}
+
+// Close 关闭底层的键值存储
+func (s *Store) Close() error {
+	return s.backend.Close()
+}
+
+// MaxAge 设置 Session 的默认有效期（秒），同时更新 Codec 的有效期
+func (s *Store) MaxAge(age int) {
+	s.keyMu.Lock()
+	defer s.keyMu.Unlock()
+	s.Options.MaxAge = age
+	setCodecMaxAge(s.codecs, age)
+}
+
+// SetKeyPairs 替换签名/加密密钥（如密钥轮换后），第一组用于签名新的 Cookie，其余只用于解码
+func (s *Store) SetKeyPairs(keyPairs ...[]byte) {
+	codecs := securecookie.CodecsFromPairs(keyPairs...)
+	s.keyMu.Lock()
+	defer s.keyMu.Unlock()
+	setCodecMaxAge(codecs, s.Options.MaxAge)
+	s.codecs = codecs
+}
+
+func (s *Store) getCodecs() []securecookie.Codec {
+	s.keyMu.RLock()
+	defer s.keyMu.RUnlock()
+	return s.codecs
+}
+
+func setCodecMaxAge(codecs []securecookie.Codec, age int) {
+	for _, c := range codecs {
+		if sc, ok := c.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(age)
+		}
+	}
+}
+
+// Get 返回请求中已注册的 Session，同一请求内多次调用返回同一个对象
+func (s *Store) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New 从 Cookie 中解出 Session ID 并加载服务端数据；Cookie 无效或数据已过期时返回一个新 Session（IsNew 为 true）
+func (s *Store) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.Options
+	session.Options = &opts
+	session.IsNew = true
+
+	c, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	if err = securecookie.DecodeMulti(name, c.Value, &session.ID, s.getCodecs()...); err != nil {
+		// 签名不对、已过期或签名密钥已轮换掉，与没有 Cookie 一样当作新 Session，保存时会覆盖旧 Cookie
+		session.ID = ""
+		return session, nil
+	}
+	err = s.load(r.Context(), session)
+	if errors.Is(err, kv.ErrNotFound) {
+		// 服务端数据已过期或被删除，视为新 Session
+		session.ID = ""
+		return session, nil
+	}
+	if err != nil {
+		return session, err
+	}
+	session.IsNew = false
+	return session, nil
+}
+
+// Save 把 Session 数据写入存储并下发带 Session ID 的 Cookie；MaxAge<=0 时删除服务端数据并让 Cookie 过期
+func (s *Store) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge <= 0 {
+		if session.ID != "" {
+			if err := s.backend.Delete(r.Context(), keyPrefix+session.ID); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	if session.ID == "" {
+		session.ID = newID()
+	}
+	if err := s.save(r.Context(), session); err != nil {
+		return err
+	}
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.getCodecs()...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+func (s *Store) save(ctx context.Context, session *sessions.Session) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(session.Values); err != nil {
+		return err
+	}
+	ttl := time.Duration(session.Options.MaxAge) * time.Second
+	return s.backend.Set(ctx, keyPrefix+session.ID, buf.Bytes(), ttl)
+}
+
+func (s *Store) load(ctx context.Context, session *sessions.Session) error {
+	b, err := s.backend.Get(ctx, keyPrefix+session.ID)
+	if err != nil {
+		return err
+	}
+	return gob.NewDecoder(bytes.NewReader(b)).Decode(&session.Values)
+}
+
+// newID 生成随机 Session ID
+func newID() string {
+	return strings.TrimRight(base32.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32)), "=")
+}
@@ -0,0 +1,108 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gin_learn/session_control/kv"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+const testName = "test_session"
+
+func newTestStore(t *testing.T) *Store {
+	backend := kv.NewMemory(time.Hour)
+	t.Cleanup(func() { _ = backend.Close() })
+	return NewStore(backend, securecookie.GenerateRandomKey(32), securecookie.GenerateRandomKey(32))
+}
+
+// request 带上 w 下发的 Cookie 发起下一个请求
+func request(w *httptest.ResponseRecorder) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range w.Result().Cookies() {
+		r.AddCookie(c)
+	}
+	return r
+}
+
+func TestStoreSaveLoad(t *testing.T) {
+	s := newTestStore(t)
+	w := httptest.NewRecorder()
+	session, err := s.New(httptest.NewRequest(http.MethodGet, "/", nil), testName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Values["k"] = "v"
+	if err = session.Save(httptest.NewRequest(http.MethodGet, "/", nil), w); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := s.New(request(w), testName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.IsNew || loaded.ID != session.ID || loaded.Values["k"] != "v" {
+		t.Fatalf("loaded session = %+v, want id %s with k=v", loaded, session.ID)
+	}
+
+	// 删除服务端数据后，同一个 Cookie 得到的是新 Session
+	if err = s.Backend().Delete(context.Background(), keyPrefix+session.ID); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err = s.New(request(w), testName); err != nil || !loaded.IsNew || loaded.ID != "" {
+		t.Fatalf("session after server-side delete = %+v, %v, want new session", loaded, err)
+	}
+}
+
+func TestStoreLoginRotatesID(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	// 匿名访问时已经有 Session（如保存了 CSRF 令牌），攻击者可以把这个 Cookie 种给受害者
+	w := httptest.NewRecorder()
+	anon, _ := s.New(httptest.NewRequest(http.MethodGet, "/", nil), testName)
+	anon.Values["csrf_token"] = "planted"
+	if err := anon.Save(httptest.NewRequest(http.MethodGet, "/", nil), w); err != nil {
+		t.Fatal(err)
+	}
+	planted := w
+
+	r := request(planted)
+	session, err := s.New(r, testName)
+	if err != nil || session.ID != anon.ID {
+		t.Fatalf("session = %+v, %v, want planted id %s", session, err, anon.ID)
+	}
+	w = httptest.NewRecorder()
+	// init 写入登录后重新生成的值（gin_session 中是新的 CSRF 令牌）
+	if err = s.Login(r, w, session, "user_id", "u1", func(sess *sessions.Session) { sess.Values["csrf_token"] = "fresh" }); err != nil {
+		t.Fatal(err)
+	}
+
+	if session.ID == anon.ID {
+		t.Fatal("Login kept the pre-login session id")
+	}
+	if session.Values["csrf_token"] != "fresh" {
+		t.Fatalf("csrf_token = %v, want the value written by init", session.Values["csrf_token"])
+	}
+	if _, err = s.Backend().Get(ctx, keyPrefix+anon.ID); !errors.Is(err, kv.ErrNotFound) {
+		t.Fatalf("pre-login session data = %v, want deleted", err)
+	}
+	// 攻击者手里的旧 Cookie 不是登录状态
+	if old, _ := s.New(request(planted), testName); old.Values["user_id"] != nil {
+		t.Fatal("planted cookie is logged in")
+	}
+	// 新 Cookie 是登录状态，且已登记到会话列表
+	if cur, _ := s.New(request(w), testName); cur.Values["user_id"] != "u1" || cur.Values["csrf_token"] != "fresh" {
+		t.Fatalf("new cookie values = %v, want user_id u1 and the fresh csrf_token", cur.Values)
+	}
+	records, err := s.Sessions(ctx, "u1")
+	if err != nil || len(records) != 1 || records[0].ID != session.ID {
+		t.Fatalf("Sessions = %+v, %v, want the new session only", records, err)
+	}
+}
@@ -0,0 +1,112 @@
+package session
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/sessions"
+)
+
+// 过期原因，作为 401 响应中的 reason 返回，前端可据此提示"长时间未操作"或"登录已满最长时间"
+const (
+	ReasonIdleTimeout     = "session_idle_timeout"
+	ReasonAbsoluteTimeout = "session_absolute_timeout"
+)
+
+// 登录时间和最后活跃时间在 Session 中的 key（Unix 秒）
+const (
+	createdKey  = "_created_at"
+	lastSeenKey = "_last_seen"
+)
+
+// Timeouts Session 过期策略
+type Timeouts struct {
+	Idle          time.Duration // 空闲超时：超过这么久没有请求即过期，每次续期后重新计时
+	Absolute      time.Duration // 绝对有效期：从登录起算，到期后无论是否活跃都要重新登录
+	RenewInterval time.Duration // 续期间隔：距上次续期不足这么久的请求不重新保存，避免每个请求都写存储和 Set-Cookie
+}
+
+// DefaultTimeouts 默认的过期策略
+var DefaultTimeouts = Timeouts{
+	Idle:          30 * time.Minute,
+	Absolute:      12 * time.Hour,
+	RenewInterval: time.Minute,
+}
+
+// maxAge 续期时 Cookie 和服务端数据的有效期（秒）：到绝对过期时间为止，再多留一个空闲超时，
+// 使过期后的第一个请求仍能读到 Session，返回明确的过期原因而不是"未登录"
+func (t Timeouts) maxAge(created, now time.Time) int {
+	return int((created.Add(t.Absolute).Sub(now) + t.Idle).Seconds())
+}
+
+// Login 登录成功后调用：换一个新的 Session ID（防止 Session 固定攻击：攻击者预先种下的 Session ID 登录后不能沿用），
+// 清空登录前的数据，把 userID 保存到 userKey，记录登录时间、保存 Session 并登记到用户的会话列表；
+// init 在清空之后、保存之前调用，用于写入登录后需要重新生成的值（如 csrf.RenewSession 生成新的 CSRF 令牌）
+func (s *Store) Login(r *http.Request, w http.ResponseWriter, session *sessions.Session, userKey string, userID any, init ...func(*sessions.Session)) error {
+	if session.ID != "" {
+		if err := s.backend.Delete(r.Context(), keyPrefix+session.ID); err != nil {
+			return err
+		}
+		session.ID = ""
+	}
+	clear(session.Values)
+	now := time.Now()
+	session.Values[userKey] = userID
+	session.Values[createdKey] = now.Unix()
+	session.Values[lastSeenKey] = now.Unix()
+	session.Options.MaxAge = s.Timeouts.maxAge(now, now)
+	for _, fn := range init {
+		fn(session)
+	}
+	if err := session.Save(r, w); err != nil {
+		return err
+	}
+	return s.Track(r, session, userID)
+}
+
+// Sliding Session 续期中间件：
+//   - 超过空闲超时或绝对有效期的 Session 被删除，返回 401 和对应的 reason
+//   - 距上次续期超过 RenewInterval 时更新最后活跃时间并重新下发 Cookie，同时更新会话列表中的 last_seen
+//
+// 没有通过 Login 登录的 Session 不受影响；userKey 为 Session 中保存用户ID的 key
+func (s *Store) Sliding(name, userKey string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, err := s.Get(c.Request, name)
+		if err != nil {
+			c.Next()
+			return
+		}
+		createdUnix, ok := session.Values[createdKey].(int64)
+		if !ok {
+			c.Next()
+			return
+		}
+		lastSeenUnix, _ := session.Values[lastSeenKey].(int64)
+		now := time.Now()
+		created, lastSeen := time.Unix(createdUnix, 0), time.Unix(lastSeenUnix, 0)
+
+		reason := ""
+		switch {
+		case now.Sub(created) >= s.Timeouts.Absolute:
+			reason = ReasonAbsoluteTimeout
+		case now.Sub(lastSeen) >= s.Timeouts.Idle:
+			reason = ReasonIdleTimeout
+		}
+		if reason != "" {
+			session.Options.MaxAge = -1
+			_ = session.Save(c.Request, c.Writer)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "登录已过期，请重新登录", "reason": reason})
+			return
+		}
+
+		if now.Sub(lastSeen) >= s.Timeouts.RenewInterval {
+			session.Values[lastSeenKey] = now.Unix()
+			session.Options.MaxAge = s.Timeouts.maxAge(created, now)
+			if err = session.Save(c.Request, c.Writer); err == nil {
+				_ = s.Touch(c.Request, session, session.Values[userKey])
+			}
+		}
+		c.Next()
+	}
+}
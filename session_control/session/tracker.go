@@ -0,0 +1,168 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"gin_learn/session_control/kv"
+
+	"github.com/gorilla/sessions"
+)
+
+// userKeyPrefix 用户 Session 索引的 key 前缀，值为该用户所有 Record 的 JSON 数组
+const userKeyPrefix = "user_sessions:"
+
+// ErrSessionNotFound 要撤销的 Session 不存在或不属于该用户
+var ErrSessionNotFound = errors.New("session: session not found")
+
+// Record 服务端记录的一个登录会话
+type Record struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	LastSeen  time.Time `json:"last_seen"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+}
+
+// Track 把已保存的 session 登记到用户的会话列表中，需在 session.Save 之后调用（此时才有 Session ID）
+func (s *Store) Track(r *http.Request, session *sessions.Session, userID any) error {
+	if session.ID == "" {
+		return fmt.Errorf("session: track unsaved session")
+	}
+	now := time.Now()
+	return s.updateUser(r.Context(), userID, func(recs []Record) []Record {
+		for i := range recs {
+			if recs[i].ID == session.ID { // 同一个 Session 重复登录
+				recs[i].LastSeen = now
+				return recs
+			}
+		}
+		return append(recs, Record{
+			ID:        session.ID,
+			CreatedAt: now,
+			LastSeen:  now,
+			IP:        clientIP(r),
+			UserAgent: r.UserAgent(),
+		})
+	})
+}
+
+// Touch 更新会话的最后访问时间和 IP
+func (s *Store) Touch(r *http.Request, session *sessions.Session, userID any) error {
+	return s.updateUser(r.Context(), userID, func(recs []Record) []Record {
+		for i := range recs {
+			if recs[i].ID == session.ID {
+				recs[i].LastSeen = time.Now()
+				recs[i].IP = clientIP(r)
+			}
+		}
+		return recs
+	})
+}
+
+// Sessions 返回用户的有效会话，顺带清理已过期的记录
+func (s *Store) Sessions(ctx context.Context, userID any) ([]Record, error) {
+	var out []Record
+	err := s.updateUser(ctx, userID, func(recs []Record) []Record {
+		out = recs
+		return recs
+	})
+	return out, err
+}
+
+// Revoke 撤销用户的一个会话：删除服务端数据，持有该 Cookie 的请求立即变为未登录
+func (s *Store) Revoke(ctx context.Context, userID any, id string) error {
+	found := false
+	err := s.updateUser(ctx, userID, func(recs []Record) []Record {
+		kept := recs[:0]
+		for _, rec := range recs {
+			if rec.ID == id {
+				found = true
+				continue
+			}
+			kept = append(kept, rec)
+		}
+		return kept
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrSessionNotFound
+	}
+	return s.backend.Delete(ctx, keyPrefix+id)
+}
+
+// RevokeAll 撤销用户的所有会话，返回撤销的数量
+func (s *Store) RevokeAll(ctx context.Context, userID any) (int, error) {
+	var revoked []Record
+	err := s.updateUser(ctx, userID, func(recs []Record) []Record {
+		revoked = recs
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, rec := range revoked {
+		if err = s.backend.Delete(ctx, keyPrefix+rec.ID); err != nil {
+			return 0, err
+		}
+	}
+	return len(revoked), nil
+}
+
+// updateUser 读取用户的会话列表（去掉服务端数据已不存在的记录），交给 fn 修改后写回
+// 单进程内由 mu 保证读-改-写不会互相覆盖
+func (s *Store) updateUser(ctx context.Context, userID any, fn func([]Record) []Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := userKeyPrefix + fmt.Sprint(userID)
+	var recs []Record
+	b, err := s.backend.Get(ctx, key)
+	switch {
+	case errors.Is(err, kv.ErrNotFound):
+	case err != nil:
+		return err
+	default:
+		if err = json.Unmarshal(b, &recs); err != nil {
+			return fmt.Errorf("session: corrupted index for user %v: %w", userID, err)
+		}
+	}
+
+	alive := recs[:0]
+	for _, rec := range recs {
+		_, err := s.backend.Get(ctx, keyPrefix+rec.ID)
+		if errors.Is(err, kv.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		alive = append(alive, rec)
+	}
+
+	recs = fn(alive)
+	if len(recs) == 0 {
+		return s.backend.Delete(ctx, key)
+	}
+	if b, err = json.Marshal(recs); err != nil {
+		return err
+	}
+	// 索引的有效期与 Session 相同，每次更新都会顺延
+	return s.backend.Set(ctx, key, b, time.Duration(s.Options.MaxAge)*time.Second)
+}
+
+// clientIP 客户端 IP（不信任 X-Forwarded-For，直接取连接地址）
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
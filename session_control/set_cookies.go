@@ -1,16 +1,39 @@
 // package main
 
+/*
+user_id、session_token 用签名+加密的 Cookie 保存（session_control/cookie），客户端看不到也改不了；
+theme 只是界面偏好，仍然明文保存。
+*/
+
 import (
+    "time"
+
+    "gin_learn/session_control/cookie"
+    "gin_learn/session_control/session"
+
     "github.com/gin-gonic/gin"
 )
 
 func main() {
+    cfg, err := session.LoadConfig("")
+    if err != nil {
+        panic(err)
+    }
+    keyring, err := session.LoadKeyring(cfg.Keyring)
+    if err != nil {
+        panic("加载Session密钥失败: " + err.Error())
+    }
+    cookies, err := cookie.New(keyring.KeyPairs(session.PurposeCookie, time.Now())...)
+    if err != nil {
+        panic(err)
+    }
+
     r := gin.Default()
 
     r.GET("/set_cookies", func(c *gin.Context) {
         // 设置多个 Cookie
-        c.SetCookie("user_id", "12345", 3600, "/", "localhost", false, true)
-        c.SetCookie("session_token", "abcdef", 3600, "/", "localhost", false, true)
+        _ = cookies.Set(c, "user_id", "12345", 3600, "/", "localhost", false, true)
+        _ = cookies.Set(c, "session_token", "abcdef", 3600, "/", "localhost", false, true)
         c.SetCookie("theme", "dark", 3600, "/", "localhost", false, true)
 
         c.JSON(200, gin.H{
@@ -20,8 +43,16 @@ func main() {
 
     r.GET("/get_cookies", func(c *gin.Context) {
         // 获取所有 Cookie
-        userID, _ := c.Cookie("user_id")
-        sessionToken, _ := c.Cookie("session_token")
+        userID, err := cookies.Get(c, "user_id")
+        if err != nil {
+            c.JSON(401, gin.H{"error": "user_id 无效", "reason": cookie.Reason(err)})
+            return
+        }
+        sessionToken, err := cookies.Get(c, "session_token")
+        if err != nil {
+            c.JSON(401, gin.H{"error": "session_token 无效", "reason": cookie.Reason(err)})
+            return
+        }
         theme, _ := c.Cookie("theme")
 
         c.JSON(200, gin.H{